@@ -0,0 +1,298 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/chenparty/gog/health"
+	"github.com/chenparty/gog/zlog"
+)
+
+type Options struct {
+	StartTimeout time.Duration // 组件单次启动的默认超时时间，默认 30 秒
+	StopTimeout  time.Duration // 组件关闭的默认超时时间，默认 10 秒
+	StartRetries int           // 组件启动失败（Start 返回错误）后的默认重试次数，默认 3 次；Start 超时后不重试
+	RetryBackoff time.Duration // 组件首次重试的默认等待时间，之后每次翻倍，默认 1 秒
+
+	Signals []os.Signal // 触发关闭的信号，默认 SIGINT、SIGTERM
+}
+
+type Option func(*Options)
+
+// App 应用运行时，按依赖顺序启动组件，收到退出信号后按启动的逆序关闭
+type App struct {
+	opts       Options
+	components []Component
+}
+
+// New 创建应用
+func New(options ...Option) *App {
+	opts := Options{
+		StartTimeout: 30 * time.Second,
+		StopTimeout:  10 * time.Second,
+		StartRetries: 3,
+		RetryBackoff: time.Second,
+		Signals:      []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	return &App{opts: opts}
+}
+
+// WithStartTimeout 设置组件单次启动的默认超时时间
+func WithStartTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		if timeout > 0 {
+			options.StartTimeout = timeout
+		}
+	}
+}
+
+// WithStopTimeout 设置组件关闭的默认超时时间
+func WithStopTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		if timeout > 0 {
+			options.StopTimeout = timeout
+		}
+	}
+}
+
+// WithStartRetries 设置组件启动失败后的默认重试次数和首次重试的等待时间
+func WithStartRetries(retries int, backoff time.Duration) Option {
+	return func(options *Options) {
+		options.StartRetries = retries
+		if backoff > 0 {
+			options.RetryBackoff = backoff
+		}
+	}
+}
+
+// WithSignals 设置触发关闭的信号
+func WithSignals(signals ...os.Signal) Option {
+	return func(options *Options) {
+		if len(signals) > 0 {
+			options.Signals = signals
+		}
+	}
+}
+
+// Add 添加组件，没有依赖关系的组件按添加顺序启动
+func (a *App) Add(components ...Component) *App {
+	a.components = append(a.components, components...)
+	return a
+}
+
+// Run 启动所有组件并阻塞，直到 ctx 取消、收到退出信号或某个组件的 Run 返回错误，然后按启动的逆序关闭组件。
+// 启动期间 health.IsReady 为 false，所有组件启动完成后置为 true，开始关闭时重新置为 false
+func (a *App) Run(ctx context.Context) (err error) {
+	health.SetReady(false)
+	ordered, err := a.sort()
+	if err != nil {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, a.opts.Signals...)
+	defer stop()
+
+	started := make([]Component, 0, len(ordered))
+	for _, c := range ordered {
+		if err = a.start(ctx, c); err != nil {
+			zlog.Error().Err(err).Str("component", c.Name).Msg("组件启动失败，开始关闭已启动的组件")
+			a.stopAll(started)
+			return
+		}
+		started = append(started, c)
+		if c.Health != nil {
+			health.Register(c.Name, c.Health)
+		}
+	}
+
+	// Run 使用独立的 context，保证关闭时先于组件的 Stop 取消
+	runCtx, cancelRun := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRun()
+	var wg sync.WaitGroup
+	runErr := make(chan error, len(started))
+	for _, c := range started {
+		if c.Run == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if e := safeCall(func() error { return c.Run(runCtx) }); e != nil && runCtx.Err() == nil {
+				runErr <- fmt.Errorf("component %s: %w", c.Name, e)
+			}
+		}()
+	}
+
+	health.SetReady(true)
+	zlog.Info().Int("components", len(started)).Msg("应用启动完成")
+
+	select {
+	case err = <-runErr:
+		zlog.Error().Err(err).Msg("组件异常退出，开始关闭应用")
+	case <-ctx.Done():
+		zlog.Info().Msg("收到退出信号，开始关闭应用")
+	}
+	// 再次收到信号时使用默认行为，直接退出进程
+	stop()
+
+	health.SetReady(false)
+	cancelRun()
+	a.stopAll(started)
+	wg.Wait()
+	zlog.Info().Msg("应用已关闭")
+	return
+}
+
+// sort 按依赖关系排序，依赖在前；不存在的依赖和循环依赖返回错误
+func (a *App) sort() (ordered []Component, err error) {
+	index := make(map[string]int, len(a.components))
+	for i, c := range a.components {
+		if c.Name == "" {
+			return nil, fmt.Errorf("app: component #%d has no name", i)
+		}
+		if _, ok := index[c.Name]; ok {
+			return nil, fmt.Errorf("app: duplicate component %q", c.Name)
+		}
+		index[c.Name] = i
+	}
+	for _, c := range a.components {
+		for _, dep := range c.DependsOn {
+			if _, ok := index[dep]; !ok {
+				return nil, fmt.Errorf("app: component %q depends on unknown component %q", c.Name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(a.components))
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		c := a.components[i]
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("app: dependency cycle %v", append(path, c.Name))
+		}
+		state[i] = visiting
+		for _, dep := range c.DependsOn {
+			if err := visit(index[dep], append(path, c.Name)); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		ordered = append(ordered, c)
+		return nil
+	}
+	for i := range a.components {
+		if err = visit(i, nil); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// start 启动组件，Start 返回错误后按指数退避重试；Start 不响应 ctx 时超时后不再等待，
+// 此时 Start 可能仍在运行，重试会重复创建连接或服务，因此直接返回错误不再重试
+func (a *App) start(ctx context.Context, c Component) (err error) {
+	if c.Start == nil {
+		return nil
+	}
+	timeout := c.StartTimeout
+	if timeout <= 0 {
+		timeout = a.opts.StartTimeout
+	}
+	retries := c.StartRetries
+	if retries == 0 {
+		retries = a.opts.StartRetries
+	}
+	backoff := c.RetryBackoff
+	if backoff <= 0 {
+		backoff = a.opts.RetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		begin := time.Now()
+		err = callWithTimeout(ctx, timeout, c.Start)
+		if err == nil {
+			zlog.Info().Str("component", c.Name).Dur("duration", time.Since(begin)).Msg("组件已启动")
+			return nil
+		}
+		if attempt >= retries || ctx.Err() != nil || errors.Is(err, errAbandoned) {
+			return fmt.Errorf("component %s: %w", c.Name, err)
+		}
+		zlog.Warn().Err(err).Str("component", c.Name).Int("attempt", attempt+1).Dur("backoff", backoff).Msg("组件启动失败，等待重试")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("component %s: %w", c.Name, errors.Join(err, ctx.Err()))
+		}
+		backoff *= 2
+	}
+}
+
+// stopAll 按启动的逆序关闭组件，单个组件关闭失败或超时不影响其它组件
+func (a *App) stopAll(started []Component) {
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		if c.Health != nil {
+			health.Unregister(c.Name)
+		}
+		if c.Stop == nil {
+			continue
+		}
+		timeout := c.StopTimeout
+		if timeout <= 0 {
+			timeout = a.opts.StopTimeout
+		}
+		if err := callWithTimeout(context.Background(), timeout, c.Stop); err != nil {
+			zlog.Error().Err(err).Str("component", c.Name).Msg("组件关闭失败")
+			continue
+		}
+		zlog.Info().Str("component", c.Name).Msg("组件已关闭")
+	}
+}
+
+// errAbandoned fn 超时后仍未返回，已不再等待
+var errAbandoned = errors.New("app: call abandoned after timeout")
+
+// callWithTimeout 在 timeout 内执行 fn，fn 不响应 ctx 时超时后直接返回 errAbandoned
+func callWithTimeout(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- safeCall(func() error { return fn(ctx) })
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", errAbandoned, ctx.Err())
+	}
+}
+
+// safeCall 将 panic 转换为错误
+func safeCall(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/chenparty/gog/server"
+)
+
+// Component 应用组件，如数据库客户端、HTTP服务、消息订阅、后台任务
+type Component struct {
+	Name      string   // 组件名称，全局唯一
+	DependsOn []string // 依赖的组件名称，依赖启动完成后才会启动，关闭时先于依赖关闭
+
+	// Start 启动组件，返回 nil 表示已就绪；返回错误时按重试配置重试，panic 会被转换为错误
+	Start func(ctx context.Context) error
+	// Run 组件启动后在后台持续运行（如HTTP服务、消费者），ctx 取消时应返回；返回错误时整个应用开始关闭
+	Run func(ctx context.Context) error
+	// Stop 关闭组件，ctx 受 StopTimeout 限制
+	Stop func(ctx context.Context) error
+	// Health 可选的健康检查，组件启动后注册到 health
+	Health func(ctx context.Context) error
+
+	StartTimeout time.Duration // 单次启动的超时时间，为 0 时使用应用的默认值
+	StopTimeout  time.Duration // 关闭的超时时间，为 0 时使用应用的默认值
+	StartRetries int           // 启动失败后的重试次数，为 0 时使用应用的默认值，小于 0 时不重试
+	RetryBackoff time.Duration // 首次重试的等待时间，之后每次翻倍，为 0 时使用应用的默认值
+}
+
+// Client 将 Connect/Close 形式的客户端包装为组件，Connect 中的 panic 会被转换为错误并重试，如
+// app.Client("mysql", func() { mysqlcli.Connect(addr, user, pwd, dbName) }, mysqlcli.Close)
+func Client(name string, connect func(), close func(), dependsOn ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			connect()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if close != nil {
+				close()
+			}
+			return nil
+		},
+	}
+}
+
+// Hook 只在启动时执行一次的组件，如注册 MQTT 订阅、创建 NATS 流
+func Hook(name string, start func(ctx context.Context) error, dependsOn ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start:     start,
+	}
+}
+
+// Job 后台任务组件，如 NATS 消费者、定时任务，run 在 ctx 取消时应返回
+func Job(name string, run func(ctx context.Context) error, dependsOn ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Run:       run,
+	}
+}
+
+// HTTPServer HTTP服务组件，启动时监听端口（端口占用等错误在启动阶段暴露并重试），关闭时优雅关闭，
+// 一般依赖所有客户端组件，保证客户端在HTTP服务停止后才关闭
+func HTTPServer(name string, srv *server.Server, dependsOn ...string) Component {
+	var ln net.Listener
+	return Component{
+		Name:        name,
+		DependsOn:   dependsOn,
+		StopTimeout: 30 * time.Second,
+		Start: func(ctx context.Context) (err error) {
+			var lc net.ListenConfig
+			ln, err = lc.Listen(ctx, "tcp", srv.Addr())
+			if err != nil {
+				return fmt.Errorf("listen %s: %w", srv.Addr(), err)
+			}
+			return nil
+		},
+		Run: func(ctx context.Context) error {
+			return srv.Serve(ln)
+		},
+		Stop: func(ctx context.Context) error {
+			err := srv.Shutdown(ctx)
+			// 后续组件启动失败时 Serve 还未调用，Shutdown 不会关闭监听，需要自行关闭释放端口
+			if ln != nil {
+				if e := ln.Close(); e != nil && !errors.Is(e, net.ErrClosed) && err == nil {
+					err = e
+				}
+			}
+			return err
+		},
+	}
+}
@@ -2,6 +2,9 @@ package etcdcli
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/chenparty/gog/client/internal/lazy"
 	"github.com/chenparty/gog/zlog"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/concurrency"
@@ -9,7 +12,8 @@ import (
 	"time"
 )
 
-var cli *clientv3.Client
+// healthName 注册到 health 的依赖名称
+const healthName = "etcd"
 
 type Locker struct {
 	session *concurrency.Session
@@ -17,24 +21,71 @@ type Locker struct {
 }
 
 type Options struct {
-	Username string
-	Password string
+	Username string `yaml:"username" env:"USERNAME"`
+	Password string `yaml:"password" env:"PASSWORD" secret:"true"`
 
-	PingKeyPrefix string
+	PingKeyPrefix string `yaml:"ping_key_prefix" env:"PING_KEY_PREFIX"`
+
+	// LazyConnect 延迟连接，etcd 不可用时不返回错误，服务以降级状态启动，客户端在 etcd 恢复后自动建立连接
+	LazyConnect bool `yaml:"lazy_connect" env:"LAZY_CONNECT"`
 }
 
 type Option func(*Options)
 
-// Connect 连接etcd
+// DefaultOptions 默认配置
+func DefaultOptions() Options {
+	return Options{}
+}
+
+// WithOptions 使用完整的配置替换当前配置，一般用于从配置文件加载
+func WithOptions(o Options) Option {
+	return func(options *Options) {
+		*options = o
+	}
+}
+
+// Client etcd 客户端实例
+type Client struct {
+	cli  *clientv3.Client
+	stop context.CancelFunc // 停止延迟连接模式下的后台等待
+}
+
+// Connect 连接etcd并设置为默认实例，连接失败时 panic
 func Connect(servers []string, options ...Option) {
-	opts := Options{}
+	if err := ConnectE(context.Background(), servers, options...); err != nil {
+		panic(err)
+	}
+}
+
+// ConnectE 连接etcd并设置为默认实例，ctx 用于控制连接超时，未设置 deadline 时默认 3 秒
+func ConnectE(ctx context.Context, servers []string, options ...Option) error {
+	c, err := NewContext(ctx, servers, options...)
+	if err != nil {
+		return err
+	}
+	SetDefault(c)
+	return nil
+}
+
+// New 创建客户端实例，连接多个 etcd 集群时通过 Register 注册为命名实例
+func New(servers []string, options ...Option) (c *Client, err error) {
+	return NewContext(context.Background(), servers, options...)
+}
+
+// NewContext 创建客户端实例，ctx 用于控制连接超时，未设置 deadline 时默认 3 秒
+func NewContext(ctx context.Context, servers []string, options ...Option) (c *Client, err error) {
+	opts := DefaultOptions()
 	for _, opt := range options {
 		if opt != nil {
 			opt(&opts)
 		}
 	}
-	var err error
-	cli, err = clientv3.New(clientv3.Config{
+	serversStr := strings.Join(servers, ",")
+	if err = validate(servers, opts); err != nil {
+		zlog.Error().Err(err).Str("servers", serversStr).Msg("etcd配置错误")
+		return
+	}
+	cli, err := clientv3.New(clientv3.Config{
 		Endpoints:           servers,
 		DialTimeout:         3 * time.Second,
 		Username:            opts.Username,
@@ -42,18 +93,68 @@ func Connect(servers []string, options ...Option) {
 		PermitWithoutStream: true,
 	})
 	if err != nil {
-		zlog.Error().Err(err).Str("servers", strings.Join(servers, ",")).Msg("etcd连接失败")
-		panic(err)
+		zlog.Error().Err(err).Str("servers", serversStr).Msg("etcd连接失败")
+		return
+	}
+	c = &Client{cli: cli}
+	if opts.LazyConnect {
+		var waitCtx context.Context
+		waitCtx, c.stop = context.WithCancel(context.Background())
+		lazy.Wait(waitCtx, "etcd", serversStr, c.Ping)
+		return
 	}
 	// 尝试发送一个请求，检查连接是否成功
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+	}
 	_, err = cli.Get(ctx, opts.PingKeyPrefix+"/ping") // 这里可以尝试获取一个存在的键
 	if err != nil {
 		zlog.Error().Err(err).Msg("etcd get失败")
-		panic(err)
+		_ = cli.Close()
+		return nil, err
+	}
+	zlog.Info().Str("servers", serversStr).Msg("etcd连接成功")
+	return
+}
+
+// validate 检查连接参数，一次返回所有问题
+func validate(servers []string, opts Options) error {
+	var errs []error
+	if len(servers) == 0 {
+		errs = append(errs, errors.New("etcdcli: servers is required"))
+	}
+	for i, server := range servers {
+		if server == "" {
+			errs = append(errs, fmt.Errorf("etcdcli: servers[%d] is empty", i))
+		}
+	}
+	if opts.Username == "" && opts.Password != "" {
+		errs = append(errs, errors.New("etcdcli: password is set without username"))
+	}
+	return errors.Join(errs...)
+}
+
+// Etcd 获取底层的 etcd 客户端
+func (c *Client) Etcd() *clientv3.Client {
+	return c.cli
+}
+
+// Ping 依次查询各节点状态，任一节点正常即视为可用
+func (c *Client) Ping(ctx context.Context) error {
+	var errs []error
+	for _, endpoint := range c.cli.Endpoints() {
+		_, err := c.cli.Status(ctx, endpoint)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return errors.New("etcd no endpoints")
 	}
-	zlog.Info().Str("servers", strings.Join(servers, ",")).Msg("etcd连接成功")
+	return errors.Join(errs...)
 }
 
 // WithUserAndPass 设置用户名密码
@@ -71,19 +172,64 @@ func WithPingKeyPrefix(prefix string) Option {
 	}
 }
 
+// WithLazyConnect 延迟连接，etcd 不可用时服务仍可启动，健康检查显示为不可用，直到 etcd 恢复
+func WithLazyConnect(enable bool) Option {
+	return func(options *Options) {
+		options.LazyConnect = enable
+	}
+}
+
 // Close 关闭连接
-func Close() {
-	_ = cli.Close()
+func (c *Client) Close() error {
+	if c.stop != nil {
+		c.stop()
+	}
+	return c.cli.Close()
 }
 
-// Put 设置 key, ttl 为租约期，单位为秒
+// Put 使用默认实例设置 key, ttl 为租约期，单位为秒
 func Put(ctx context.Context, key, value string, ttl int64) (err error) {
+	return Default().Put(ctx, key, value, ttl)
+}
+
+// Get 使用默认实例根据key获取value
+func Get(ctx context.Context, key string) (val string, isNotExist bool, err error) {
+	return Default().Get(ctx, key)
+}
+
+// GetPrefix 使用默认实例获取前缀下的所有 key
+func GetPrefix(ctx context.Context, prefix string) (kvs map[string]string, rev int64, err error) {
+	return Default().GetPrefix(ctx, prefix)
+}
+
+// WatchPrefix 使用默认实例监听前缀下的变更
+func WatchPrefix(ctx context.Context, prefix string, rev int64) clientv3.WatchChan {
+	return Default().WatchPrefix(ctx, prefix, rev)
+}
+
+// NewLocker 使用默认实例创建一个锁
+func NewLocker(ttl int) (l *Locker, err error) {
+	return Default().NewLocker(ttl)
+}
+
+// NewLockerAndLock 使用默认实例新建阻塞锁+锁key
+func NewLockerAndLock(ctx context.Context, lockKey string, ttl int) (lock *Locker, err error) {
+	return Default().NewLockerAndLock(ctx, lockKey, ttl)
+}
+
+// NewTryLockerAndLock 使用默认实例新建尝试锁+锁key
+func NewTryLockerAndLock(ctx context.Context, lockKey string, ttl int) (lock *Locker, err error) {
+	return Default().NewTryLockerAndLock(ctx, lockKey, ttl)
+}
+
+// Put 设置 key, ttl 为租约期，单位为秒
+func (c *Client) Put(ctx context.Context, key, value string, ttl int64) (err error) {
 	if ttl <= 0 {
-		_, err = cli.Put(ctx, key, value)
+		_, err = c.cli.Put(ctx, key, value)
 		return
 	}
 	// 创建一个租约对象
-	lease := clientv3.NewLease(cli)
+	lease := clientv3.NewLease(c.cli)
 	// 根据时间，生成一个租约
 	leaseResp, err := lease.Grant(ctx, ttl)
 	if err != nil {
@@ -98,13 +244,13 @@ func Put(ctx context.Context, key, value string, ttl int64) (err error) {
 		}
 	}()
 	// 设置 key，并绑定租约
-	_, err = cli.Put(ctx, key, value, clientv3.WithLease(leaseResp.ID))
+	_, err = c.cli.Put(ctx, key, value, clientv3.WithLease(leaseResp.ID))
 	return
 }
 
 // Get 根据key获取value
-func Get(ctx context.Context, key string) (val string, isNotExist bool, err error) {
-	resp, err := cli.Get(ctx, key)
+func (c *Client) Get(ctx context.Context, key string) (val string, isNotExist bool, err error) {
+	resp, err := c.cli.Get(ctx, key)
 	if err != nil {
 		return
 	}
@@ -116,9 +262,33 @@ func Get(ctx context.Context, key string) (val string, isNotExist bool, err erro
 	return
 }
 
+// GetPrefix 获取前缀下的所有 key，rev 为本次读取时的版本号，从 rev+1 开始监听可以不遗漏变更
+func (c *Client) GetPrefix(ctx context.Context, prefix string) (kvs map[string]string, rev int64, err error) {
+	resp, err := c.cli.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return
+	}
+	kvs = make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		kvs[string(kv.Key)] = string(kv.Value)
+	}
+	rev = resp.Header.Revision
+	return
+}
+
+// WatchPrefix 监听前缀下的变更，rev 大于 0 时从该版本开始监听。
+// 要求连接到有 leader 的节点，网络分区时返回错误而不是一直阻塞；ctx 取消后通道关闭
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, rev int64) clientv3.WatchChan {
+	opts := []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithPrevKV()}
+	if rev > 0 {
+		opts = append(opts, clientv3.WithRev(rev))
+	}
+	return c.cli.Watch(clientv3.WithRequireLeader(ctx), prefix, opts...)
+}
+
 // NewLocker 创建一个锁
-func NewLocker(ttl int) (l *Locker, err error) {
-	session, err := concurrency.NewSession(cli, concurrency.WithTTL(ttl))
+func (c *Client) NewLocker(ttl int) (l *Locker, err error) {
+	session, err := concurrency.NewSession(c.cli, concurrency.WithTTL(ttl))
 	if err != nil {
 		return
 	}
@@ -134,6 +304,11 @@ func (l *Locker) Close() (err error) {
 	return
 }
 
+// Done 锁的租约失效（如与 etcd 断开超过 ttl）时关闭，此后锁可能已被其它实例获取
+func (l *Locker) Done() <-chan struct{} {
+	return l.session.Done()
+}
+
 // Lock 阻塞式获取锁，锁被占用时则阻塞等待
 func (l *Locker) Lock(ctx context.Context, key string) (err error) {
 	l.mutex = concurrency.NewMutex(l.session, key)
@@ -155,8 +330,8 @@ func (l *Locker) UnLock(ctx context.Context) (err error) {
 }
 
 // NewLockerAndLock 新建阻塞锁+锁key
-func NewLockerAndLock(ctx context.Context, lockKey string, ttl int) (lock *Locker, err error) {
-	lock, err = NewLocker(ttl)
+func (c *Client) NewLockerAndLock(ctx context.Context, lockKey string, ttl int) (lock *Locker, err error) {
+	lock, err = c.NewLocker(ttl)
 	if err != nil {
 		return
 	}
@@ -165,8 +340,8 @@ func NewLockerAndLock(ctx context.Context, lockKey string, ttl int) (lock *Locke
 }
 
 // NewTryLockerAndLock 新建尝试锁+锁key
-func NewTryLockerAndLock(ctx context.Context, lockKey string, ttl int) (lock *Locker, err error) {
-	lock, err = NewLocker(ttl)
+func (c *Client) NewTryLockerAndLock(ctx context.Context, lockKey string, ttl int) (lock *Locker, err error) {
+	lock, err = c.NewLocker(ttl)
 	if err != nil {
 		return
 	}
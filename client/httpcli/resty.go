@@ -3,10 +3,12 @@ package httpcli
 import (
 	"context"
 	"errors"
+	"github.com/chenparty/gog/metrics"
 	"github.com/chenparty/gog/zlog"
 	"github.com/chenparty/gog/zlog/ginplugin"
 	"github.com/go-resty/resty/v2"
 	"net/url"
+	"strconv"
 	"time"
 )
 
@@ -15,7 +17,38 @@ var client *resty.Client
 func init() {
 	client = resty.New().
 		SetTimeout(10*time.Second).
-		SetHeader("User-Agent", "httpcli/1.0")
+		SetHeader("User-Agent", "httpcli/1.0").
+		OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+			metrics.ObserveHTTPClient(resp.Request.Method, requestHost(resp.Request), resp.StatusCode(), resp.Time())
+			return nil
+		}).
+		OnError(func(req *resty.Request, err error) {
+			// 有响应的错误已在 OnAfterResponse 中记录
+			var respErr *resty.ResponseError
+			if errors.As(err, &respErr) {
+				return
+			}
+			metrics.ObserveHTTPClient(req.Method, requestHost(req), 0, time.Since(req.Time))
+		})
+}
+
+// setPropagationHeaders 向下游传递 trace_id 和剩余处理时间
+func setPropagationHeaders(ctx context.Context, header map[string]string) {
+	header[ginplugin.HeaderRequestID] = zlog.TraceIDFromContext(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		header[ginplugin.HeaderRequestTimeout] = strconv.FormatInt(time.Until(deadline).Milliseconds(), 10)
+	}
+}
+
+// requestHost 获取请求的目标 host，作为指标标签
+func requestHost(req *resty.Request) string {
+	if req.RawRequest != nil {
+		return req.RawRequest.URL.Host
+	}
+	if u, err := url.Parse(req.URL); err == nil {
+		return u.Host
+	}
+	return ""
 }
 
 func PostJson(ctx context.Context, reqUrl string, header map[string]string, body any) (statusCode int, respBody []byte, err error) {
@@ -33,7 +66,7 @@ func PostJson(ctx context.Context, reqUrl string, header map[string]string, body
 		header = make(map[string]string)
 	}
 	header["Content-Type"] = "application/json"
-	header[ginplugin.HeaderRequestID] = zlog.TraceIDFromContext(ctx)
+	setPropagationHeaders(ctx, header)
 
 	req := client.R().
 		SetContext(ctx).
@@ -76,9 +109,10 @@ func Get(ctx context.Context, reqUrl string, header map[string]string, queryPara
 	if header == nil {
 		header = make(map[string]string)
 	}
-	header[ginplugin.HeaderRequestID] = zlog.TraceIDFromContext(ctx)
+	setPropagationHeaders(ctx, header)
 
 	req := client.R().
+		SetContext(ctx).
 		SetHeaders(header).
 		SetQueryParams(queryParam)
 
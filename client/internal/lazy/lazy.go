@@ -0,0 +1,40 @@
+// Package lazy 延迟连接模式下，在后台等待依赖可用
+package lazy
+
+import (
+	"context"
+	"time"
+
+	"github.com/chenparty/gog/zlog"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// Wait 在后台按指数退避调用 ping，直到成功或 ctx 取消，只记录首次失败和最终成功的日志，
+// 连接池本身会在依赖恢复后自动建立连接，这里只用于观察依赖何时可用
+func Wait(ctx context.Context, kind, addr string, ping func(ctx context.Context) error) {
+	go func() {
+		backoff := minBackoff
+		for attempt := 1; ; attempt++ {
+			pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			err := ping(pingCtx)
+			cancel()
+			if err == nil {
+				zlog.Info().Str("addr", addr).Int("attempt", attempt).Msg(kind + " 后台连接成功")
+				return
+			}
+			if attempt == 1 {
+				zlog.Warn().Str("addr", addr).Err(err).Msg(kind + " 暂不可用，后台重试连接")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}()
+}
@@ -0,0 +1,127 @@
+// Package registry 客户端实例的命名注册表，供各客户端包共用
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/chenparty/gog/health"
+)
+
+// DefaultName 默认实例的名称，包级函数使用该实例
+const DefaultName = "default"
+
+// Client 可以检查连接状态和关闭的客户端实例
+type Client interface {
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Registry 按名称保存客户端实例，注册时同时注册到 health。
+// 各客户端包的 Register、Use、Default、Close 等包级函数直接使用其方法值
+type Registry[T Client] struct {
+	kind      string
+	onDefault func(c T)
+	mu        sync.RWMutex // 保护clients
+	clients   map[string]T
+}
+
+// New 创建注册表，kind 为客户端类型，如 "mysql"，用作 health 中的依赖名称
+func New[T Client](kind string) *Registry[T] {
+	return &Registry[T]{kind: kind, clients: map[string]T{}}
+}
+
+// OnDefault 设置默认实例注册后的回调，如 mqttcli 转交默认实例创建前记录的订阅
+func (r *Registry[T]) OnDefault(fn func(c T)) *Registry[T] {
+	r.onDefault = fn
+	return r
+}
+
+// HealthName 实例注册到 health 的名称，默认实例为 kind，其它实例为 kind:name
+func (r *Registry[T]) HealthName(name string) string {
+	if name == DefaultName {
+		return r.kind
+	}
+	return r.kind + ":" + name
+}
+
+// Register 注册实例，同名实例会被替换但不会被关闭
+func (r *Registry[T]) Register(name string, c T) {
+	r.mu.Lock()
+	r.clients[name] = c
+	r.mu.Unlock()
+	health.Register(r.HealthName(name), c.Ping)
+	if name == DefaultName && r.onDefault != nil {
+		r.onDefault(c)
+	}
+}
+
+// Unregister 移除实例，返回被移除的实例
+func (r *Registry[T]) Unregister(name string) (c T, ok bool) {
+	r.mu.Lock()
+	c, ok = r.clients[name]
+	delete(r.clients, name)
+	r.mu.Unlock()
+	if ok {
+		health.Unregister(r.HealthName(name))
+	}
+	return
+}
+
+// Lookup 按名称查找实例
+func (r *Registry[T]) Lookup(name string) (c T, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok = r.clients[name]
+	return
+}
+
+// MustLookup 按名称查找实例，实例不存在时 panic
+func (r *Registry[T]) MustLookup(name string) T {
+	c, ok := r.Lookup(name)
+	if !ok {
+		panic(fmt.Sprintf("%s client %q not registered", r.kind, name))
+	}
+	return c
+}
+
+// SetDefault 设置默认实例
+func (r *Registry[T]) SetDefault(c T) {
+	r.Register(DefaultName, c)
+}
+
+// Default 获取默认实例，未注册时返回零值
+func (r *Registry[T]) Default() T {
+	c, _ := r.Lookup(DefaultName)
+	return c
+}
+
+// Close 关闭并移除默认实例
+func (r *Registry[T]) Close() {
+	if c, ok := r.Unregister(DefaultName); ok {
+		_ = c.Close()
+	}
+}
+
+// CloseAll 关闭并移除所有实例
+func (r *Registry[T]) CloseAll() {
+	for _, name := range r.Names() {
+		if c, ok := r.Unregister(name); ok {
+			_ = c.Close()
+		}
+	}
+}
+
+// Names 已注册的实例名称
+func (r *Registry[T]) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.clients))
+	for name := range r.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
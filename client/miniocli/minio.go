@@ -2,54 +2,144 @@ package miniocli
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/chenparty/gog/zlog"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
-var minioClient *minio.Client
+const (
+	// healthName 注册到 health 的依赖名称
+	healthName = "minio"
+	// defaultHealthBucket 未配置探测桶时使用的桶名，桶不存在也能证明服务可访问
+	defaultHealthBucket = "gog-health-probe"
+)
 
+// Client 获取默认实例的 minio 客户端
 func Client() *minio.Client {
-	if minioClient == nil {
+	c := Default()
+	if c == nil {
 		panic("请先调用Connect方法连接minio")
 	}
-	return minioClient
+	return c.client
 }
 
 type Options struct {
-	AccessKeyID     string
-	SecretAccessKey string
+	AccessKeyID     string `yaml:"access_key_id" env:"ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secret_access_key" env:"SECRET_ACCESS_KEY" secret:"true"`
+
+	UseSSL bool `yaml:"use_ssl" env:"USE_SSL"` // 默认启用 SSL，生产环境应该使用 SSL
 
-	useSSL bool // 默认启用 SSL，生产环境应该使用 SSL
+	HealthBucket string `yaml:"health_bucket" env:"HEALTH_BUCKET"` // 健康检查时探测的桶
 }
 
 type Option func(*Options)
 
-// Connect 连接 minio
+// DefaultOptions 默认配置
+func DefaultOptions() Options {
+	return Options{
+		UseSSL: true, // 默认启用 SSL
+	}
+}
+
+// WithOptions 使用完整的配置替换当前配置，一般用于从配置文件加载，应基于 DefaultOptions 修改
+func WithOptions(o Options) Option {
+	return func(options *Options) {
+		*options = o
+	}
+}
+
+// Instance minio 客户端实例，包级函数 Client 已用于获取默认的 *minio.Client，因此命名为 Instance
+type Instance struct {
+	client       *minio.Client
+	healthBucket string
+}
+
+// Connect 连接 minio 并设置为默认实例，创建失败时 panic
 func Connect(addr string, options ...Option) {
-	opts := Options{
-		useSSL: true, // 默认启用 SSL
+	if err := ConnectE(context.Background(), addr, options...); err != nil {
+		panic(err)
+	}
+}
+
+// ConnectE 连接 minio 并设置为默认实例，配置错误时返回错误
+func ConnectE(ctx context.Context, addr string, options ...Option) error {
+	c, err := NewContext(ctx, addr, options...)
+	if err != nil {
+		return err
 	}
+	SetDefault(c)
+	return nil
+}
+
+// New 创建客户端实例，连接多个存储服务时通过 Register 注册为命名实例
+func New(addr string, options ...Option) (c *Instance, err error) {
+	return NewContext(context.Background(), addr, options...)
+}
+
+// NewContext 创建客户端实例。minio 客户端基于 HTTP，创建时不建立连接（相当于始终是延迟连接），
+// 服务是否可访问由健康检查反映，ctx 已取消时直接返回错误
+func NewContext(ctx context.Context, addr string, options ...Option) (c *Instance, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	opts := DefaultOptions()
 	for _, opt := range options {
 		if opt != nil {
 			opt(&opts)
 		}
 	}
+	if err = validate(addr, opts); err != nil {
+		zlog.Error().Str("addr", addr).Err(err).Msg("minio配置错误")
+		return
+	}
 	minioOptions := minio.Options{
 		Creds:  credentials.NewStaticV4(opts.AccessKeyID, opts.SecretAccessKey, ""),
-		Secure: opts.useSSL,
+		Secure: opts.UseSSL,
 	}
-	var err error
-	minioClient, err = minio.New(addr, &minioOptions)
+	client, err := minio.New(addr, &minioOptions)
 	if err != nil {
 		zlog.Error().Str("addr", addr).Err(err).Msg("minio连接失败")
-		panic(err)
+		return
+	}
+	c = &Instance{client: client, healthBucket: opts.HealthBucket}
+	if c.healthBucket == "" {
+		c.healthBucket = defaultHealthBucket
 	}
 	zlog.Info().Str("addr", addr).Msg("minio连接成功")
+	return
+}
+
+// validate 检查连接参数，一次返回所有问题
+func validate(addr string, opts Options) error {
+	var errs []error
+	if addr == "" {
+		errs = append(errs, errors.New("miniocli: addr is required"))
+	}
+	if strings.Contains(addr, "://") {
+		errs = append(errs, fmt.Errorf("miniocli: addr %q must not contain scheme, use WithSSL instead", addr))
+	}
+	if (opts.AccessKeyID == "") != (opts.SecretAccessKey == "") {
+		errs = append(errs, errors.New("miniocli: access key id and secret access key must be set together"))
+	}
+	return errors.Join(errs...)
+}
+
+// Minio 获取底层的 minio 客户端
+func (c *Instance) Minio() *minio.Client {
+	return c.client
+}
+
+// Ping 通过查询探测桶是否存在检查服务是否可访问
+func (c *Instance) Ping(ctx context.Context) error {
+	_, err := c.client.BucketExists(ctx, c.healthBucket)
+	return err
 }
 
 // WithAccess 设置访问密钥
@@ -63,40 +153,77 @@ func WithAccess(id, secret string) Option {
 // WithSSL 使用SSL
 func WithSSL(useSSL bool) Option {
 	return func(options *Options) {
-		options.useSSL = useSSL
+		options.UseSSL = useSSL
 	}
 }
 
-// CheckBucket 检查桶是否存在，不存在则创建
+// WithHealthBucket 设置健康检查时探测的桶，建议使用业务实际使用的桶，以便同时验证权限
+func WithHealthBucket(bucket string) Option {
+	return func(options *Options) {
+		options.HealthBucket = bucket
+	}
+}
+
+// CheckBucket 使用默认实例检查桶是否存在，不存在则创建
 func CheckBucket(ctx context.Context, bucketName string) (err error) {
+	return Default().CheckBucket(ctx, bucketName)
+}
+
+// PutObject 使用默认实例上传对象
+func PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (err error) {
+	return Default().PutObject(ctx, bucketName, objectName, reader, objectSize, contentType)
+}
+
+// DelObject 使用默认实例删除对象
+func DelObject(ctx context.Context, bucketName, objName string) (err error) {
+	return Default().DelObject(ctx, bucketName, objName)
+}
+
+// PreSignedGetObject 使用默认实例生成带有授权访问的临时URL
+func PreSignedGetObject(ctx context.Context, bucketName, objName string, expiration time.Duration) (url string, err error) {
+	return Default().PreSignedGetObject(ctx, bucketName, objName, expiration)
+}
+
+// PreSignedGetObjectByCustom 使用默认实例生成带有授权访问的临时URL,可自定义请求参数和请求头
+func PreSignedGetObjectByCustom(ctx context.Context, bucketName, objName string, expiration time.Duration, reqParams url.Values, presignExtraHeaders http.Header) (url string, err error) {
+	return Default().PreSignedGetObjectByCustom(ctx, bucketName, objName, expiration, reqParams, presignExtraHeaders)
+}
+
+// GetObject 使用默认实例获取对象
+func GetObject(ctx context.Context, bucketName, objName string) (obj *minio.Object, err error) {
+	return Default().GetObject(ctx, bucketName, objName)
+}
+
+// CheckBucket 检查桶是否存在，不存在则创建
+func (c *Instance) CheckBucket(ctx context.Context, bucketName string) (err error) {
 	// 检查桶是否存在
-	exists, err := minioClient.BucketExists(ctx, bucketName)
+	exists, err := c.client.BucketExists(ctx, bucketName)
 	if err != nil {
 		return
 	}
 	if !exists {
 		// 创建新桶
-		err = minioClient.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
+		err = c.client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
 	}
 	return
 }
 
 // PutObject 上传对象
-func PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (err error) {
+func (c *Instance) PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (err error) {
 	options := minio.PutObjectOptions{ContentType: contentType}
-	_, err = minioClient.PutObject(ctx, bucketName, objectName, reader, objectSize, options)
+	_, err = c.client.PutObject(ctx, bucketName, objectName, reader, objectSize, options)
 	return
 }
 
 // DelObject 删除对象
-func DelObject(ctx context.Context, bucketName, objName string) (err error) {
-	err = minioClient.RemoveObject(ctx, bucketName, objName, minio.RemoveObjectOptions{})
+func (c *Instance) DelObject(ctx context.Context, bucketName, objName string) (err error) {
+	err = c.client.RemoveObject(ctx, bucketName, objName, minio.RemoveObjectOptions{})
 	return
 }
 
 // PreSignedGetObject 生成带有授权访问的临时URL
-func PreSignedGetObject(ctx context.Context, bucketName, objName string, expiration time.Duration) (url string, err error) {
-	preSignedURL, err := minioClient.PresignedGetObject(ctx, bucketName, objName, expiration, nil)
+func (c *Instance) PreSignedGetObject(ctx context.Context, bucketName, objName string, expiration time.Duration) (url string, err error) {
+	preSignedURL, err := c.client.PresignedGetObject(ctx, bucketName, objName, expiration, nil)
 	if err != nil {
 		return
 	}
@@ -105,8 +232,8 @@ func PreSignedGetObject(ctx context.Context, bucketName, objName string, expirat
 }
 
 // PreSignedGetObjectByCustom 生成带有授权访问的临时URL,可自定义请求参数和请求头
-func PreSignedGetObjectByCustom(ctx context.Context, bucketName, objName string, expiration time.Duration, reqParams url.Values, presignExtraHeaders http.Header) (url string, err error) {
-	preSignedURL, err := minioClient.PresignHeader(ctx, http.MethodGet, bucketName, objName, expiration, reqParams, presignExtraHeaders)
+func (c *Instance) PreSignedGetObjectByCustom(ctx context.Context, bucketName, objName string, expiration time.Duration, reqParams url.Values, presignExtraHeaders http.Header) (url string, err error) {
+	preSignedURL, err := c.client.PresignHeader(ctx, http.MethodGet, bucketName, objName, expiration, reqParams, presignExtraHeaders)
 	if err != nil {
 		return
 	}
@@ -115,12 +242,13 @@ func PreSignedGetObjectByCustom(ctx context.Context, bucketName, objName string,
 }
 
 // GetObject 获取对象
-func GetObject(ctx context.Context, bucketName, objName string) (obj *minio.Object, err error) {
-	obj, err = minioClient.GetObject(ctx, bucketName, objName, minio.GetObjectOptions{})
+func (c *Instance) GetObject(ctx context.Context, bucketName, objName string) (obj *minio.Object, err error) {
+	obj, err = c.client.GetObject(ctx, bucketName, objName, minio.GetObjectOptions{})
 	return
 }
 
 // Close 关闭 Minio 连接（Minio 客户端不需要显式关闭，此方法仅为接口一致性）
-func Close() {
+func (c *Instance) Close() error {
 	zlog.Debug().Msg("Minio 客户端已关闭（无实际操作）")
+	return nil
 }
@@ -1,11 +1,14 @@
 package mqttcli
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/chenparty/gog/metrics"
 	"github.com/chenparty/gog/zlog"
 	MQTT "github.com/eclipse/paho.mqtt.golang"
 	"github.com/oklog/ulid/v2"
@@ -13,33 +16,98 @@ import (
 
 type MsgHandler func(ID uint16, topic string, payload []byte)
 
-var (
-	subscribes  = map[string]MsgHandler{}
-	subTopicQos = map[string]byte{}
-	mu          sync.RWMutex // 保护subscribes和subTopicQos
-	mqttClient  MQTT.Client
+// ConnectionStatus 客户端连接状态
+type ConnectionStatus string
+
+const (
+	StatusUninitialized ConnectionStatus = "uninitialized"
+	StatusConnected     ConnectionStatus = "connected"
+	StatusDisconnected  ConnectionStatus = "disconnected"
 )
 
+// healthName 注册到 health 的依赖名称
+const healthName = "mqtt"
+
 type Options struct {
-	ClientID string // 客户端ID,不设置时会自动随机生成
-	Username string // 用户名
-	Password string // 密码
+	ClientID string `yaml:"client_id" env:"CLIENT_ID"`             // 客户端ID,不设置时会自动随机生成
+	Username string `yaml:"username" env:"USERNAME"`               // 用户名
+	Password string `yaml:"password" env:"PASSWORD" secret:"true"` // 密码
 
-	tls *tls.Config
+	// TLS 客户端证书，同时设置时使用 TLS 认证，等同于 AuthWithTLS
+	CertFile string `yaml:"cert_file" env:"CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"KEY_FILE"`
+
+	// LazyConnect 延迟连接，Broker 不可用时不返回错误，服务以降级状态启动，在后台持续尝试连接
+	LazyConnect bool `yaml:"lazy_connect" env:"LAZY_CONNECT"`
+
+	tls    *tls.Config
+	tlsErr error // 加载 TLS 证书的错误，创建客户端时返回
 }
 
 type Option func(*Options)
 
-// Connect 连接MQTT服务器
-func Connect(addr string, options ...Option) {
-	opts := Options{
+// DefaultOptions 默认配置，ClientID 为随机生成
+func DefaultOptions() Options {
+	return Options{
 		ClientID: ulid.Make().String(),
 	}
+}
+
+// WithOptions 使用完整的配置替换当前配置，一般用于从配置文件加载，应基于 DefaultOptions 修改
+func WithOptions(o Options) Option {
+	return func(options *Options) {
+		*options = o
+		if o.CertFile != "" || o.KeyFile != "" {
+			AuthWithTLS(o.CertFile, o.KeyFile)(options)
+		}
+	}
+}
+
+// Client MQTT 客户端实例，断线重连后自动恢复订阅
+type Client struct {
+	client      MQTT.Client
+	subscribes  map[string]MsgHandler
+	subTopicQos map[string]byte
+	mu          sync.RWMutex // 保护subscribes和subTopicQos
+}
+
+// errNotConnected 客户端未创建或未连接
+var errNotConnected = errors.New("MQTT 客户端未连接")
+
+// Connect 连接MQTT服务器并设置为默认实例，连接失败时 panic
+func Connect(addr string, options ...Option) {
+	if err := ConnectE(context.Background(), addr, options...); err != nil {
+		panic(err)
+	}
+}
+
+// ConnectE 连接MQTT服务器并设置为默认实例，ctx 用于控制连接超时
+func ConnectE(ctx context.Context, addr string, options ...Option) error {
+	c, err := NewContext(ctx, addr, options...)
+	if err != nil {
+		return err
+	}
+	SetDefault(c)
+	return nil
+}
+
+// New 创建客户端实例，连接多个 Broker 时通过 Register 注册为命名实例
+func New(addr string, options ...Option) (c *Client, err error) {
+	return NewContext(context.Background(), addr, options...)
+}
+
+// NewContext 创建客户端实例，ctx 用于控制连接超时
+func NewContext(ctx context.Context, addr string, options ...Option) (c *Client, err error) {
+	opts := DefaultOptions()
 	for _, opt := range options {
 		if opt != nil {
 			opt(&opts)
 		}
 	}
+	if err = validate(addr, opts); err != nil {
+		zlog.Error().Str("addr", addr).Err(err).Msg("MQTT配置错误")
+		return
+	}
 	clientOptions := MQTT.NewClientOptions()
 	clientOptions.AddBroker(addr)
 	clientOptions.SetClientID(opts.ClientID)
@@ -59,33 +127,72 @@ func Connect(addr string, options ...Option) {
 	clientOptions.SetKeepAlive(30 * time.Second)            // 设置KeepAlive为60秒，根据实际情况调整
 	clientOptions.SetPingTimeout(10 * time.Second)          // 设置PingTimeout为10秒，根据实际情况调整
 	clientOptions.SetMaxReconnectInterval(30 * time.Second) // 最大重连间隔
+	if opts.LazyConnect {
+		// 首次连接失败时在后台重试，连接成功后调用 OnConnect
+		clientOptions.SetConnectRetry(true)
+		clientOptions.SetConnectRetryInterval(5 * time.Second)
+	}
 
+	c = &Client{
+		subscribes:  map[string]MsgHandler{},
+		subTopicQos: map[string]byte{},
+	}
 	// 添加连接状态监控
-	clientOptions.OnConnect = onConnectHandler(addr)
+	clientOptions.OnConnect = c.onConnectHandler(addr)
 	clientOptions.OnConnectionLost = onConnectionLostHandler(addr)
 	clientOptions.OnReconnecting = onReconnectingHandler(addr)
 
-	mqttClient = MQTT.NewClient(clientOptions)
-	if token := mqttClient.Connect(); token.Wait() && token.Error() != nil {
-		zlog.Error().Str("addr", addr).Err(token.Error()).Msg("MQTT连接失败")
-		panic(token.Error())
+	c.client = MQTT.NewClient(clientOptions)
+	token := c.client.Connect()
+	if opts.LazyConnect {
+		return
+	}
+	select {
+	case <-token.Done():
+	case <-ctx.Done():
+		c.client.Disconnect(0)
+		zlog.Error().Str("addr", addr).Err(ctx.Err()).Msg("MQTT连接超时")
+		return nil, ctx.Err()
+	}
+	if err = token.Error(); err != nil {
+		zlog.Error().Str("addr", addr).Err(err).Msg("MQTT连接失败")
+		return nil, err
+	}
+	return
+}
+
+// validate 检查连接参数，一次返回所有问题
+func validate(addr string, opts Options) error {
+	var errs []error
+	if addr == "" {
+		errs = append(errs, errors.New("mqttcli: addr is required"))
 	}
+	if opts.ClientID == "" {
+		errs = append(errs, errors.New("mqttcli: client id is required"))
+	}
+	if opts.Username == "" && opts.Password != "" {
+		errs = append(errs, errors.New("mqttcli: password is set without username"))
+	}
+	if opts.tlsErr != nil {
+		errs = append(errs, fmt.Errorf("mqttcli: load tls certificate: %w", opts.tlsErr))
+	}
+	return errors.Join(errs...)
 }
 
 // 连接成功回调
-func onConnectHandler(addr string) func(MQTT.Client) {
+func (c *Client) onConnectHandler(addr string) func(MQTT.Client) {
 	return func(client MQTT.Client) {
 		zlog.Info().Str("addr", addr).Msg("MQTT 连接成功")
 
-		mu.RLock()
+		c.mu.RLock()
 		subscribeSnapshot := make([]struct {
 			topic   string
 			qos     byte
 			handler func(uint16, string, []byte)
-		}, 0, len(subscribes))
+		}, 0, len(c.subscribes))
 
-		for topic, handler := range subscribes {
-			qos, ok := subTopicQos[topic]
+		for topic, handler := range c.subscribes {
+			qos, ok := c.subTopicQos[topic]
 			if !ok {
 				qos = 0
 				zlog.Warn().Str("topic", topic).Msg("QoS 未配置，使用默认值 0")
@@ -100,12 +207,10 @@ func onConnectHandler(addr string) func(MQTT.Client) {
 				handler: handler,
 			})
 		}
-		mu.RUnlock()
+		c.mu.RUnlock()
 
 		for _, sub := range subscribeSnapshot {
-			token := client.Subscribe(sub.topic, sub.qos, func(client MQTT.Client, message MQTT.Message) {
-				sub.handler(message.MessageID(), message.Topic(), message.Payload())
-			})
+			token := client.Subscribe(sub.topic, sub.qos, messageHandler(sub.topic, sub.handler))
 
 			if !token.WaitTimeout(3 * time.Second) {
 				zlog.Error().Str("topic", sub.topic).Msg("MQTT 重新订阅超时")
@@ -134,12 +239,11 @@ func onReconnectingHandler(addr string) func(MQTT.Client, *MQTT.ClientOptions) {
 	}
 }
 
-// Close 关闭MQTT连接
-func Close() {
-	if mqttClient != nil && mqttClient.IsConnected() {
-		mqttClient.Disconnect(250) // 增加断开等待时间
-		zlog.Info().Msg("MQTT连接已关闭")
-	}
+// Close 关闭MQTT连接；未连接时也调用 Disconnect，停止 LazyConnect 和自动重连的后台重试
+func (c *Client) Close() error {
+	c.client.Disconnect(250) // 增加断开等待时间
+	zlog.Info().Msg("MQTT连接已关闭")
+	return nil
 }
 
 // WithClientID 设置客户端ID,仅仅作为前缀时会自动拼接随机串
@@ -163,34 +267,110 @@ func AuthWithUser(username, pwd string) Option {
 	}
 }
 
-// AuthWithTLS TLS认证
+// WithLazyConnect 延迟连接，Broker 不可用时服务仍可启动，健康检查显示为不可用，直到连接成功
+func WithLazyConnect(enable bool) Option {
+	return func(options *Options) {
+		options.LazyConnect = enable
+	}
+}
+
+// AuthWithTLS TLS认证，证书加载失败时创建客户端返回错误
 func AuthWithTLS(certFile, keyFile string) Option {
 	return func(options *Options) {
 		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
 		if err != nil {
-			// 更好的做法是在 Options 中增加一个 error 字段，在 NewClient 时检查
-			zlog.Error().Err(err).Str("cert", certFile).Msg("加载 TLS 证书失败")
+			// 创建客户端时返回该错误，避免在未启用 TLS 的情况下继续连接
+			options.tlsErr = err
 			return
 		}
+		options.tlsErr = nil
 		options.tls = &tls.Config{
 			Certificates: []tls.Certificate{cert},
 		}
 	}
 }
 
-// Subscribe 订阅主题
+// pendingSubscription 默认实例创建前的订阅
+type pendingSubscription struct {
+	qos      byte
+	callback MsgHandler
+}
+
+var (
+	pendingSubscribes = map[string]pendingSubscription{}
+	pendingMu         sync.Mutex // 保护pendingSubscribes
+)
+
+// Subscribe 使用默认实例订阅主题；默认实例还未创建时记录订阅并返回错误，设置默认实例后自动订阅
 func Subscribe(topic string, qos byte, callback MsgHandler) error {
-	mu.Lock()
-	subscribes[topic] = callback
-	subTopicQos[topic] = qos
-	mu.Unlock()
-	if mqttClient == nil || !mqttClient.IsConnected() {
+	pendingMu.Lock()
+	c := Default()
+	if c == nil {
+		pendingSubscribes[topic] = pendingSubscription{qos: qos, callback: callback}
+		pendingMu.Unlock()
+		zlog.Error().Str("topic", topic).Msg("MQTT 客户端未连接，连接后自动订阅")
+		return errNotConnected
+	}
+	pendingMu.Unlock()
+	return c.Subscribe(topic, qos, callback)
+}
+
+// subscribePending 将默认实例创建前的订阅转交给 c，c 未连接时在连接成功后订阅
+func subscribePending(c *Client) {
+	pendingMu.Lock()
+	subs := pendingSubscribes
+	pendingSubscribes = map[string]pendingSubscription{}
+	pendingMu.Unlock()
+	for topic, sub := range subs {
+		_ = c.Subscribe(topic, sub.qos, sub.callback)
+	}
+}
+
+// Publish 使用默认实例发布消息
+func Publish(topic string, qos byte, payload any) error {
+	return Default().Publish(topic, qos, payload)
+}
+
+// PublishWithContext 使用默认实例发布消息，等待服务端确认的时间受 ctx 限制
+func PublishWithContext(ctx context.Context, topic string, qos byte, payload any) error {
+	return Default().PublishWithContext(ctx, topic, qos, payload)
+}
+
+// IsConnected 默认实例的连接状态检查
+func IsConnected() bool {
+	return Default().IsConnected()
+}
+
+// Status 获取默认实例的连接状态
+func Status() ConnectionStatus {
+	return Default().Status()
+}
+
+// GetConnectionStatus 获取客户端状态
+//
+// Deprecated: 返回值为中文描述，不便于程序判断，请使用 Status
+func GetConnectionStatus() string {
+	switch Status() {
+	case StatusConnected:
+		return "已连接"
+	case StatusDisconnected:
+		return "未连接"
+	default:
+		return "未初始化"
+	}
+}
+
+// Subscribe 订阅主题，断线重连后自动重新订阅
+func (c *Client) Subscribe(topic string, qos byte, callback MsgHandler) error {
+	c.mu.Lock()
+	c.subscribes[topic] = callback
+	c.subTopicQos[topic] = qos
+	c.mu.Unlock()
+	if !c.client.IsConnected() {
 		zlog.Error().Str("topic", topic).Msg("MQTT 客户端未连接，中断订阅")
-		return fmt.Errorf("MQTT 客户端未连接")
+		return errNotConnected
 	}
-	token := mqttClient.Subscribe(topic, qos, func(client MQTT.Client, message MQTT.Message) {
-		callback(message.MessageID(), message.Topic(), message.Payload())
-	})
+	token := c.client.Subscribe(topic, qos, messageHandler(topic, callback))
 	if !token.WaitTimeout(3 * time.Second) {
 		zlog.Error().Str("topic", topic).Msg("MQTT 订阅超时")
 		return fmt.Errorf("MQTT 订阅超时")
@@ -205,16 +385,27 @@ func Subscribe(topic string, qos byte, callback MsgHandler) error {
 	return nil
 }
 
+// messageHandler 将 MsgHandler 适配为 paho 的回调，并统计订阅收到的消息数
+func messageHandler(topic string, handler MsgHandler) MQTT.MessageHandler {
+	return func(client MQTT.Client, message MQTT.Message) {
+		metrics.IncMQTTReceive(topic)
+		handler(message.MessageID(), message.Topic(), message.Payload())
+	}
+}
+
 // Publish 发布消息
-func Publish(topic string, qos byte, payload any) error {
-	if mqttClient == nil || !mqttClient.IsConnected() {
+func (c *Client) Publish(topic string, qos byte, payload any) error {
+	if !c.IsConnected() {
 		zlog.Error().Str("topic", topic).Msg("MQTT 客户端未连接，发布失败")
-		return fmt.Errorf("MQTT 客户端未连接")
+		metrics.IncMQTTPublish(errNotConnected)
+		return errNotConnected
 	}
 
-	token := mqttClient.Publish(topic, qos, false, payload)
+	token := c.client.Publish(topic, qos, false, payload)
 	token.Wait()
-	if err := token.Error(); err != nil {
+	err := token.Error()
+	metrics.IncMQTTPublish(err)
+	if err != nil {
 		zlog.Error().Str("topic", topic).Err(err).Msg("MQTT 发布失败")
 		return err
 	}
@@ -223,18 +414,53 @@ func Publish(topic string, qos byte, payload any) error {
 	return nil
 }
 
-// IsConnected 连接状态检查
-func IsConnected() bool {
-	return mqttClient != nil && mqttClient.IsConnected()
+// PublishWithContext 发布消息，等待服务端确认的时间受 ctx 限制
+func (c *Client) PublishWithContext(ctx context.Context, topic string, qos byte, payload any) error {
+	if !c.IsConnected() {
+		zlog.Error().Ctx(ctx).Str("topic", topic).Msg("MQTT 客户端未连接，发布失败")
+		metrics.IncMQTTPublish(errNotConnected)
+		return errNotConnected
+	}
+
+	token := c.client.Publish(topic, qos, false, payload)
+	select {
+	case <-token.Done():
+	case <-ctx.Done():
+		metrics.IncMQTTPublish(ctx.Err())
+		zlog.Error().Ctx(ctx).Str("topic", topic).Err(ctx.Err()).Msg("MQTT 发布超时")
+		return ctx.Err()
+	}
+	err := token.Error()
+	metrics.IncMQTTPublish(err)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Str("topic", topic).Err(err).Msg("MQTT 发布失败")
+		return err
+	}
+
+	zlog.Debug().Ctx(ctx).Str("topic", topic).Msg("MQTT 发布成功")
+	return nil
 }
 
-// GetConnectionStatus 获取客户端状态
-func GetConnectionStatus() string {
-	if mqttClient == nil {
-		return "未初始化"
+// IsConnected 连接状态检查，c 为 nil 时返回 false
+func (c *Client) IsConnected() bool {
+	return c != nil && c.client.IsConnected()
+}
+
+// Status 获取客户端连接状态，c 为 nil 时返回 StatusUninitialized
+func (c *Client) Status() ConnectionStatus {
+	if c == nil {
+		return StatusUninitialized
 	}
-	if mqttClient.IsConnected() {
-		return "已连接"
+	if c.client.IsConnected() {
+		return StatusConnected
+	}
+	return StatusDisconnected
+}
+
+// Ping 客户端会自动重连，这里只检查当前是否处于连接状态
+func (c *Client) Ping(_ context.Context) error {
+	if status := c.Status(); status != StatusConnected {
+		return errors.New("mqtt " + string(status))
 	}
-	return "未连接"
+	return nil
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/chenparty/gog/client/internal/lazy"
 	"github.com/chenparty/gog/zlog"
 	"github.com/chenparty/gog/zlog/gormplugin"
 
@@ -14,36 +15,84 @@ import (
 	"gorm.io/gorm/schema"
 )
 
-var db *gorm.DB
+// healthName 注册到 health 的依赖名称
+const healthName = "mysql"
+
+// Client MySQL 客户端实例
+type Client struct {
+	db   *gorm.DB
+	stop context.CancelFunc // 停止延迟连接模式下的后台等待
+}
 
 type Options struct {
-	TablePrefix   string // 表名前缀
-	SingularTable bool   // 使用单数表名
+	TablePrefix   string `yaml:"table_prefix" env:"TABLE_PREFIX"`     // 表名前缀
+	SingularTable bool   `yaml:"singular_table" env:"SINGULAR_TABLE"` // 使用单数表名
 
 	// Logger
-	Silent                    bool          // 是否打印sql语句
-	ParameterizedQueries      bool          // 使用参数化查询
-	IgnoreRecordNotFoundError bool          // 忽略记录不存在错误
-	SlowThreshold             time.Duration // 慢查询阈值
+	Silent                    bool          `yaml:"silent" env:"SILENT"`                                               // 是否打印sql语句
+	ParameterizedQueries      bool          `yaml:"parameterized_queries" env:"PARAMETERIZED_QUERIES"`                 // 使用参数化查询
+	IgnoreRecordNotFoundError bool          `yaml:"ignore_record_not_found_error" env:"IGNORE_RECORD_NOT_FOUND_ERROR"` // 忽略记录不存在错误
+	SlowThreshold             time.Duration `yaml:"slow_threshold" env:"SLOW_THRESHOLD"`                               // 慢查询阈值
+
+	// LazyConnect 延迟连接，数据库不可用时不返回错误，服务以降级状态启动，连接池在数据库恢复后自动建立连接
+	LazyConnect bool `yaml:"lazy_connect" env:"LAZY_CONNECT"`
 }
 
 type Option func(*Options)
 
-// Connect 连接数据库
-func Connect(addr, user, pwd, dbName string, options ...Option) {
-	opts := Options{
+// DefaultOptions 默认配置
+func DefaultOptions() Options {
+	return Options{
 		SingularTable: true,
 		SlowThreshold: time.Second,
 	}
+}
+
+// WithOptions 使用完整的配置替换当前配置，一般用于从配置文件加载，应基于 DefaultOptions 修改
+func WithOptions(o Options) Option {
+	return func(options *Options) {
+		*options = o
+	}
+}
+
+// Connect 连接数据库并设置为默认实例，连接失败时 panic
+func Connect(addr, user, pwd, dbName string, options ...Option) {
+	if err := ConnectE(context.Background(), addr, user, pwd, dbName, options...); err != nil {
+		panic(err)
+	}
+}
+
+// ConnectE 连接数据库并设置为默认实例，ctx 用于控制连接超时
+func ConnectE(ctx context.Context, addr, user, pwd, dbName string, options ...Option) error {
+	c, err := NewContext(ctx, addr, user, pwd, dbName, options...)
+	if err != nil {
+		return err
+	}
+	SetDefault(c)
+	return nil
+}
+
+// New 创建客户端实例，多个数据库时通过 Register 注册为命名实例
+func New(addr, user, pwd, dbName string, options ...Option) (c *Client, err error) {
+	return NewContext(context.Background(), addr, user, pwd, dbName, options...)
+}
+
+// NewContext 创建客户端实例，ctx 用于控制连接超时
+func NewContext(ctx context.Context, addr, user, pwd, dbName string, options ...Option) (c *Client, err error) {
+	opts := DefaultOptions()
 	for _, opt := range options {
 		if opt != nil {
 			opt(&opts)
 		}
 	}
+	if err = validate(addr, user, dbName, opts); err != nil {
+		zlog.Error().Str("addr", addr).Err(err).Msg("mysql 配置错误")
+		return
+	}
 	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", user, pwd, addr, dbName)
-	var err error
-	db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 		DisableForeignKeyConstraintWhenMigrating: true,
+		DisableAutomaticPing:                     true, // 由下方按 ctx 和延迟连接模式决定是否验证连接
 		NamingStrategy: schema.NamingStrategy{
 			TablePrefix:   opts.TablePrefix,   // 表名前缀，`User` 的表名应该是 `t_users`
 			SingularTable: opts.SingularTable, // 使用单数表名，启用该选项，此时，`User` 的表名应该是 `t_user`
@@ -57,19 +106,62 @@ func Connect(addr, user, pwd, dbName string, options ...Option) {
 	})
 	if err != nil {
 		zlog.Error().Str("addr", addr).Err(err).Msg("mysql 连接失败")
-		panic(err)
+		return
+	}
+	if err = db.Use(gormplugin.NewMetrics()); err != nil {
+		zlog.Error().Str("addr", addr).Err(err).Msg("mysql 注册指标插件失败")
+		return
 	}
-	// 验证数据库连接
 	sqlDB, err := db.DB()
 	if err != nil {
 		zlog.Error().Str("addr", addr).Err(err).Msg("mysql 获取底层连接失败")
-		panic(err)
+		return
 	}
-	if err = sqlDB.Ping(); err != nil {
+	c = &Client{db: db}
+	if opts.LazyConnect {
+		var waitCtx context.Context
+		waitCtx, c.stop = context.WithCancel(context.Background())
+		lazy.Wait(waitCtx, "mysql", addr, sqlDB.PingContext)
+		return
+	}
+	// 验证数据库连接
+	if err = sqlDB.PingContext(ctx); err != nil {
 		zlog.Error().Str("addr", addr).Err(err).Msg("mysql 连接测试失败")
-		panic(err)
+		_ = sqlDB.Close()
+		return nil, err
 	}
 	zlog.Info().Str("addr", addr).Msg("mysql 连接成功")
+	return
+}
+
+// NewFromDB 使用已创建的 gorm.DB 创建客户端实例，如测试中使用 sqlite 替代数据库
+func NewFromDB(db *gorm.DB) *Client {
+	return &Client{db: db}
+}
+
+// validate 检查连接参数，一次返回所有问题
+func validate(addr, user, dbName string, opts Options) error {
+	var errs []error
+	if addr == "" {
+		errs = append(errs, errors.New("mysqlcli: addr is required"))
+	}
+	if user == "" {
+		errs = append(errs, errors.New("mysqlcli: user is required"))
+	}
+	if dbName == "" {
+		errs = append(errs, errors.New("mysqlcli: dbName is required"))
+	}
+	if opts.SlowThreshold < 0 {
+		errs = append(errs, fmt.Errorf("mysqlcli: invalid slow threshold %s", opts.SlowThreshold))
+	}
+	return errors.Join(errs...)
+}
+
+// WithLazyConnect 延迟连接，数据库不可用时服务仍可启动，健康检查显示为不可用，直到数据库恢复
+func WithLazyConnect(enable bool) Option {
+	return func(options *Options) {
+		options.LazyConnect = enable
+	}
 }
 
 // WithSilent 设置是否打印sql语句
@@ -100,9 +192,9 @@ func WithSlowThreshold(slowThreshold time.Duration) Option {
 	}
 }
 
-// DB 获取数据库连接
+// DB 获取默认实例的数据库连接
 func DB(ctx context.Context) *gorm.DB {
-	return db.WithContext(ctx)
+	return Default().DB(ctx)
 }
 
 // IsRecordNotFoundErr 判断是否记录不存在错误
@@ -113,14 +205,29 @@ func IsRecordNotFoundErr(err error) bool {
 // TxOperation 事务操作
 type TxOperation func(tx *gorm.DB) error
 
-// StartTransaction 开启事务
+// StartTransaction 使用默认实例开启事务
 func StartTransaction(ctx context.Context, trans TxOperation) error {
-	return db.WithContext(ctx).Transaction(trans)
+	return Default().StartTransaction(ctx, trans)
 }
 
-// ExecuteInTx 批量执行事务
+// ExecuteInTx 使用默认实例批量执行事务
 func ExecuteInTx(ctx context.Context, ops ...TxOperation) error {
-	return StartTransaction(ctx, func(tx *gorm.DB) error {
+	return Default().ExecuteInTx(ctx, ops...)
+}
+
+// DB 获取数据库连接
+func (c *Client) DB(ctx context.Context) *gorm.DB {
+	return c.db.WithContext(ctx)
+}
+
+// StartTransaction 开启事务
+func (c *Client) StartTransaction(ctx context.Context, trans TxOperation) error {
+	return c.db.WithContext(ctx).Transaction(trans)
+}
+
+// ExecuteInTx 批量执行事务
+func (c *Client) ExecuteInTx(ctx context.Context, ops ...TxOperation) error {
+	return c.StartTransaction(ctx, func(tx *gorm.DB) error {
 		for _, op := range ops {
 			if err := op(tx); err != nil {
 				return err
@@ -130,13 +237,26 @@ func ExecuteInTx(ctx context.Context, ops ...TxOperation) error {
 	})
 }
 
+// Ping 检查数据库连接
+func (c *Client) Ping(ctx context.Context) error {
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 // Close 关闭数据库连接
-func Close() {
-	if db != nil {
-		sqlDB, err := db.DB()
-		if err == nil {
-			_ = sqlDB.Close()
-			zlog.Info().Msg("MySQL 连接已关闭")
-		}
+func (c *Client) Close() (err error) {
+	if c.stop != nil {
+		c.stop()
+	}
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return
+	}
+	if err = sqlDB.Close(); err == nil {
+		zlog.Info().Msg("MySQL 连接已关闭")
 	}
+	return
 }
@@ -0,0 +1,30 @@
+package mysqlcli
+
+import "github.com/chenparty/gog/client/internal/registry"
+
+// DefaultName 默认实例的名称，Connect 创建的实例使用该名称
+const DefaultName = registry.DefaultName
+
+var clients = registry.New[*Client](healthName)
+
+// 命名实例的注册和查找，包级函数都使用默认实例
+var (
+	// Register 注册命名实例，同时注册到 health，依赖名称为 "mysql:<name>"；同名实例会被替换但不会被关闭
+	Register = clients.Register
+	// Unregister 移除命名实例，不会关闭连接
+	Unregister = clients.Unregister
+	// Use 获取命名实例，实例未注册时 panic
+	Use = clients.MustLookup
+	// Lookup 查找命名实例
+	Lookup = clients.Lookup
+	// Names 已注册的实例名称
+	Names = clients.Names
+	// SetDefault 设置默认实例
+	SetDefault = clients.SetDefault
+	// Default 获取默认实例，未连接时返回 nil
+	Default = clients.Default
+	// Close 关闭并移除默认实例
+	Close = clients.Close
+	// CloseAll 关闭并移除所有实例
+	CloseAll = clients.CloseAll
+)
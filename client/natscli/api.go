@@ -3,51 +3,123 @@ package natscli
 import (
 	"context"
 	"encoding/json"
+	"github.com/chenparty/gog/metrics"
 	"github.com/chenparty/gog/zlog"
 	"github.com/nats-io/nats.go"
 	"time"
 )
 
 func Pub(ctx context.Context, subj string, data []byte) (err error) {
-	err = nc.Publish(subj, data)
+	return Default().Pub(ctx, subj, data)
+}
+
+func PubGo(ctx context.Context, subj string, data any) (err error) {
+	return Default().PubGo(ctx, subj, data)
+}
+
+func Request(subj string, data []byte, timeout time.Duration) (*nats.Msg, error) {
+	return Default().Request(subj, data, timeout)
+}
+
+func RequestGo(subj string, data any, timeout time.Duration) (*nats.Msg, error) {
+	return Default().RequestGo(subj, data, timeout)
+}
+
+// RequestWithContext 发起请求，超时时间由 ctx 的 deadline 决定，ctx 必须设置 deadline 或可被取消
+func RequestWithContext(ctx context.Context, subj string, data []byte) (msg *nats.Msg, err error) {
+	return Default().RequestWithContext(ctx, subj, data)
+}
+
+// RequestGoWithContext 使用 json 编码请求数据，超时时间由 ctx 的 deadline 决定
+func RequestGoWithContext(ctx context.Context, subj string, data any) (*nats.Msg, error) {
+	return Default().RequestGoWithContext(ctx, subj, data)
+}
+
+func Sub(subj string, handler nats.MsgHandler) (err error) {
+	return Default().Sub(subj, handler)
+}
+
+func QueueSub(subj, queue string, handler nats.MsgHandler) (err error) {
+	return Default().QueueSub(subj, queue, handler)
+}
+
+func QueueSubSyncWithChan(subject, queueName string, handler chan *nats.Msg) (sub *nats.Subscription, err error) {
+	return Default().QueueSubSyncWithChan(subject, queueName, handler)
+}
+
+func (c *Client) Pub(ctx context.Context, subj string, data []byte) (err error) {
+	err = c.nc.Publish(subj, data)
+	metrics.IncNATSPublish(err)
 	if err != nil {
 		zlog.Error().Ctx(ctx).Err(err).Str("subj", subj).Msg("nc.Publish")
 	}
 	return
 }
 
-func PubGo(ctx context.Context, subj string, data any) (err error) {
+func (c *Client) PubGo(ctx context.Context, subj string, data any) (err error) {
 	bs, err := json.Marshal(data)
 	if err != nil {
 		zlog.Error().Ctx(ctx).Err(err).Str("subj", subj).Msg("json.Marshal")
 		return
 	}
-	return Pub(ctx, subj, bs)
+	return c.Pub(ctx, subj, bs)
 }
 
-func Request(subj string, data []byte, timeout time.Duration) (*nats.Msg, error) {
-	return nc.Request(subj, data, timeout)
+func (c *Client) Request(subj string, data []byte, timeout time.Duration) (*nats.Msg, error) {
+	return c.nc.Request(subj, data, timeout)
 }
 
-func RequestGo(subj string, data any, timeout time.Duration) (*nats.Msg, error) {
+func (c *Client) RequestGo(subj string, data any, timeout time.Duration) (*nats.Msg, error) {
 	bs, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
 	}
-	return nc.Request(subj, bs, timeout)
+	return c.nc.Request(subj, bs, timeout)
 }
 
-func Sub(subj string, handler nats.MsgHandler) (err error) {
-	_, err = nc.Subscribe(subj, handler)
+// RequestWithContext 发起请求，超时时间由 ctx 的 deadline 决定，ctx 必须设置 deadline 或可被取消
+func (c *Client) RequestWithContext(ctx context.Context, subj string, data []byte) (msg *nats.Msg, err error) {
+	msg, err = c.nc.RequestWithContext(ctx, subj, data)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("subj", subj).Msg("nc.RequestWithContext")
+	}
 	return
 }
 
-func QueueSub(subj, queue string, handler nats.MsgHandler) (err error) {
-	_, err = nc.QueueSubscribe(subj, queue, handler)
+// RequestGoWithContext 使用 json 编码请求数据，超时时间由 ctx 的 deadline 决定
+func (c *Client) RequestGoWithContext(ctx context.Context, subj string, data any) (*nats.Msg, error) {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("subj", subj).Msg("json.Marshal")
+		return nil, err
+	}
+	return c.RequestWithContext(ctx, subj, bs)
+}
+
+// Sub 订阅消息，需要取消订阅时使用 Subscribe
+func (c *Client) Sub(subj string, handler nats.MsgHandler) (err error) {
+	_, err = c.Subscribe(subj, handler)
 	return
 }
 
-func QueueSubSyncWithChan(subject, queueName string, handler chan *nats.Msg) (sub *nats.Subscription, err error) {
-	sub, err = nc.QueueSubscribeSyncWithChan(subject, queueName, handler)
+// QueueSub 队列方式订阅消息，需要取消订阅时使用 Subscribe 和 WithQueue
+func (c *Client) QueueSub(subj, queue string, handler nats.MsgHandler) (err error) {
+	_, err = c.Subscribe(subj, handler, WithQueue(queue))
 	return
 }
+
+func (c *Client) QueueSubSyncWithChan(subject, queueName string, handler chan *nats.Msg) (sub *nats.Subscription, err error) {
+	sub, err = c.nc.QueueSubscribeSyncWithChan(subject, queueName, handler)
+	if err == nil {
+		c.register(sub, subject, queueName, false)
+	}
+	return
+}
+
+// countReceived 包装订阅回调，统计订阅收到的消息数
+func countReceived(subj string, handler nats.MsgHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		metrics.IncNATSReceive(subj)
+		handler(msg)
+	}
+}
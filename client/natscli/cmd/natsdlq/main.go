@@ -0,0 +1,99 @@
+// natsdlq 查看和重放 JetStream 死信队列
+//
+//	natsdlq -server nats://localhost:4222 -stream DLQ list
+//	natsdlq -server nats://localhost:4222 -stream DLQ replay -seq 12
+//	natsdlq -server nats://localhost:4222 -stream DLQ replay -subject orders.created
+//	natsdlq -server nats://localhost:4222 -stream DLQ replay -all
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chenparty/gog/client/natscli"
+	"github.com/chenparty/gog/zlog"
+)
+
+func main() {
+	server := flag.String("server", "nats://localhost:4222", "NATS 地址，多个用逗号分隔")
+	user := flag.String("user", "", "用户名")
+	pass := flag.String("pass", "", "密码")
+	token := flag.String("token", "", "TOKEN")
+	stream := flag.String("stream", "DLQ", "死信流")
+	start := flag.Uint64("start", 0, "list: 起始序号")
+	limit := flag.Int("limit", 100, "list: 最多显示的消息数")
+	data := flag.Bool("data", false, "list: 显示消息内容")
+	seq := flag.Uint64("seq", 0, "replay: 重放指定序号的消息")
+	subject := flag.String("subject", "", "replay: 重放原 subject 为该值的消息")
+	all := flag.Bool("all", false, "replay: 重放所有消息")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: natsdlq [flags] list|replay")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	zlog.NewLogLogger("stdout", "error")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	options := []natscli.Option{natscli.WithJetStream(true)}
+	if *user != "" {
+		options = append(options, natscli.WithUserAndPass(*user, *pass))
+	}
+	if *token != "" {
+		options = append(options, natscli.WithToken(*token))
+	}
+	c, err := natscli.NewContext(ctx, "natsdlq", strings.Split(*server, ","), options...)
+	if err != nil {
+		fatal(err)
+	}
+	defer c.Close()
+
+	switch flag.Arg(0) {
+	case "list":
+		letters, err := c.DeadLetters(ctx, *stream, *start, *limit)
+		if err != nil {
+			fatal(err)
+		}
+		for _, dl := range letters {
+			fmt.Printf("%d\t%s\t%s/%s#%d\tdeliveries=%d\t%s\t%s\n", dl.Sequence, dl.Time.Format(time.RFC3339),
+				dl.Stream, dl.Consumer, dl.OriginalSequence, dl.Deliveries, dl.Subject, dl.Error)
+			if *data {
+				fmt.Printf("\t%s\n", dl.Data)
+			}
+		}
+	case "replay":
+		switch {
+		case *seq > 0:
+			if err = c.ReplayDeadLetter(ctx, *stream, *seq); err != nil {
+				fatal(err)
+			}
+			fmt.Println("replayed 1")
+		case *subject != "" || *all:
+			n, err := c.ReplayDeadLetters(ctx, *stream, func(dl natscli.DeadLetter) bool {
+				return *all || dl.Subject == *subject
+			})
+			fmt.Printf("replayed %d\n", n)
+			if err != nil {
+				fatal(err)
+			}
+		default:
+			fatal(fmt.Errorf("replay requires -seq, -subject or -all"))
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "natsdlq:", err)
+	os.Exit(1)
+}
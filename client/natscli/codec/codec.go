@@ -0,0 +1,117 @@
+// Package codec 消息编解码，按 Content-Type 选择 JSON、Protobuf、MessagePack、CBOR
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// HeaderContentType 消息头中标识编码的字段
+const HeaderContentType = "Content-Type"
+
+// Codec 消息编解码
+type Codec interface {
+	// ContentType 编码对应的 Content-Type，如 "application/json"
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	JSON     Codec = jsonCodec{}
+	Protobuf Codec = protobufCodec{}
+	MsgPack  Codec = msgpackCodec{}
+	CBOR     Codec = cborCodec{}
+)
+
+var (
+	mu     sync.RWMutex
+	codecs = map[string]Codec{}
+)
+
+func init() {
+	Register(JSON)
+	Register(Protobuf, "application/x-protobuf")
+	Register(MsgPack, "application/x-msgpack")
+	Register(CBOR)
+}
+
+// Register 注册编解码，aliases 为同一编码的其它 Content-Type；同名的编解码会被替换
+func Register(c Codec, aliases ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	codecs[c.ContentType()] = c
+	for _, alias := range aliases {
+		codecs[alias] = c
+	}
+}
+
+// Lookup 按 Content-Type 查找编解码，忽略大小写和参数（如 "application/json; charset=utf-8"）
+func Lookup(contentType string) (c Codec, ok bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok = codecs[mediaType]
+	return
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string                { return "application/json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string                { return "application/msgpack" }
+func (msgpackCodec) Marshal(v any) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
+
+type cborCodec struct{}
+
+func (cborCodec) ContentType() string                { return "application/cbor" }
+func (cborCodec) Marshal(v any) ([]byte, error)      { return cbor.Marshal(v) }
+func (cborCodec) Unmarshal(data []byte, v any) error { return cbor.Unmarshal(data, v) }
+
+// protobufCodec 只支持 proto.Message，Unmarshal 的参数可以是 proto.Message 或指向它的指针（如 **pb.Order）
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string { return "application/protobuf" }
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("codec: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+	// 泛型中 T 为 *pb.Order 时传入的是 **pb.Order，创建消息后再解析
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().Kind() == reflect.Pointer {
+		elem := reflect.New(rv.Elem().Type().Elem())
+		if m, ok := elem.Interface().(proto.Message); ok {
+			if err := proto.Unmarshal(data, m); err != nil {
+				return err
+			}
+			rv.Elem().Set(elem)
+			return nil
+		}
+	}
+	return fmt.Errorf("codec: %T is not a proto.Message", v)
+}
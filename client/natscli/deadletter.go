@@ -0,0 +1,302 @@
+package natscli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chenparty/gog/zlog"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// 死信消息头，记录消息的来源和最后一次处理的错误
+const (
+	HeaderDLQSubject    = "Dlq-Subject"    // 原消息的 subject，重放时发布到该 subject
+	HeaderDLQStream     = "Dlq-Stream"     // 原消息所在的流
+	HeaderDLQConsumer   = "Dlq-Consumer"   // 处理失败的消费者
+	HeaderDLQSequence   = "Dlq-Sequence"   // 原消息在流中的序号
+	HeaderDLQDeliveries = "Dlq-Deliveries" // 投递次数
+	HeaderDLQError      = "Dlq-Error"      // 最后一次处理的错误
+	HeaderDLQTime       = "Dlq-Time"       // 进入死信队列的时间，RFC3339
+)
+
+// DefaultDeadLetterPrefix 默认的死信 subject 前缀，死信发布到 "dlq.<流>.<消费者>"，
+// 需要创建保存死信的流，如 jetstream.StreamConfig{Name: "DLQ", Subjects: []string{"dlq.>"}}
+const DefaultDeadLetterPrefix = "dlq"
+
+// deadLetterPageSize ReplayDeadLetters 每次读取的死信数
+const deadLetterPageSize = 100
+
+// RetryOptions 重试和死信选项
+type RetryOptions struct {
+	// MaxDeliveries 最大投递次数，默认 5；达到后消息发布到死信 subject。
+	// 消费者的 MaxDeliver 需要为 -1 或大于该值，否则服务端会先停止投递
+	MaxDeliveries int
+	// Backoff 首次重试的延迟，之后每次翻倍，默认 1 秒
+	Backoff time.Duration
+	// MaxBackoff 重试延迟的上限，默认 5 分钟
+	MaxBackoff time.Duration
+	// DeadLetterSubject 死信 subject，默认 "dlq.<流>.<消费者>"
+	DeadLetterSubject string
+}
+
+type RetryOption func(*RetryOptions)
+
+// WithMaxDeliveries 设置最大投递次数
+func WithMaxDeliveries(n int) RetryOption {
+	return func(options *RetryOptions) {
+		options.MaxDeliveries = n
+	}
+}
+
+// WithBackoff 设置首次重试的延迟和延迟上限
+func WithBackoff(initial, max time.Duration) RetryOption {
+	return func(options *RetryOptions) {
+		options.Backoff = initial
+		options.MaxBackoff = max
+	}
+}
+
+// WithDeadLetterSubject 设置死信 subject
+func WithDeadLetterSubject(subj string) RetryOption {
+	return func(options *RetryOptions) {
+		options.DeadLetterSubject = subj
+	}
+}
+
+// DeadLetter 死信队列中的消息
+type DeadLetter struct {
+	Sequence         uint64 // 在死信流中的序号
+	Subject          string // 原消息的 subject
+	Stream           string // 原消息所在的流
+	Consumer         string
+	OriginalSequence uint64 // 原消息在流中的序号
+	Deliveries       uint64
+	Error            string
+	Time             time.Time // 进入死信队列的时间
+	Header           nats.Header
+	Data             []byte
+}
+
+// RetryHandler 使用默认实例包装 handler，失败时按指数退避重试，投递次数用尽后发布到死信 subject
+func RetryHandler(handler JsMsgHandler, options ...RetryOption) JsMsgHandler {
+	return Default().RetryHandler(handler, options...)
+}
+
+// DeadLetters 使用默认实例查看死信
+func DeadLetters(ctx context.Context, stream string, startSeq uint64, limit int) ([]DeadLetter, error) {
+	return Default().DeadLetters(ctx, stream, startSeq, limit)
+}
+
+// ReplayDeadLetter 使用默认实例重放一条死信
+func ReplayDeadLetter(ctx context.Context, stream string, seq uint64) error {
+	return Default().ReplayDeadLetter(ctx, stream, seq)
+}
+
+// ReplayDeadLetters 使用默认实例重放死信
+func ReplayDeadLetters(ctx context.Context, stream string, filter func(DeadLetter) bool) (int, error) {
+	return Default().ReplayDeadLetters(ctx, stream, filter)
+}
+
+// RetryHandler 包装 handler：返回错误时按投递次数指数退避重试（返回 RetryAfter 时使用其延迟），
+// 投递次数达到 MaxDeliveries 或返回 Terminate 时，将消息连同错误信息头发布到死信 subject 后放弃；
+// 死信发布失败时消息重新投递，不会丢失
+func (c *Client) RetryHandler(handler JsMsgHandler, options ...RetryOption) JsMsgHandler {
+	opts := RetryOptions{MaxDeliveries: 5, Backoff: time.Second, MaxBackoff: 5 * time.Minute}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	return func(ctx context.Context, msg jetstream.Msg) error {
+		err := handler(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		meta, metaErr := msg.Metadata()
+		if metaErr != nil {
+			return err
+		}
+		var term *terminateError
+		if !errors.As(err, &term) && meta.NumDelivered < uint64(opts.MaxDeliveries) {
+			var retry *retryAfterError
+			if errors.As(err, &retry) {
+				return err
+			}
+			return RetryAfter(err, backoff(opts.Backoff, opts.MaxBackoff, meta.NumDelivered))
+		}
+		subj := opts.DeadLetterSubject
+		if subj == "" {
+			subj = DefaultDeadLetterPrefix + "." + meta.Stream + "." + meta.Consumer
+		}
+		if dlqErr := c.publishDeadLetter(ctx, subj, msg, meta, err); dlqErr != nil {
+			zlog.Error().Ctx(ctx).Err(dlqErr).Str("dlq", subj).Str("subj", msg.Subject()).Msg("死信发布失败")
+			return RetryAfter(err, opts.MaxBackoff)
+		}
+		zlog.Error().Ctx(ctx).Err(err).Str("stream", meta.Stream).Str("consumer", meta.Consumer).Str("subj", msg.Subject()).
+			Uint64("seq", meta.Sequence.Stream).Uint64("deliveries", meta.NumDelivered).Str("dlq", subj).Msg("JetStream消息进入死信队列")
+		return Terminate(err)
+	}
+}
+
+// backoff 第 n 次投递失败后的重试延迟
+func backoff(initial, max time.Duration, n uint64) time.Duration {
+	if n < 1 {
+		n = 1
+	}
+	d := float64(initial) * math.Pow(2, float64(n-1))
+	if max > 0 && d > float64(max) {
+		return max
+	}
+	return time.Duration(d)
+}
+
+func (c *Client) publishDeadLetter(ctx context.Context, subj string, msg jetstream.Msg, meta *jetstream.MsgMetadata, cause error) (err error) {
+	dl := nats.NewMsg(subj)
+	dl.Data = msg.Data()
+	for k, v := range msg.Headers() {
+		dl.Header[k] = v
+	}
+	dl.Header.Set(HeaderDLQSubject, msg.Subject())
+	dl.Header.Set(HeaderDLQStream, meta.Stream)
+	dl.Header.Set(HeaderDLQConsumer, meta.Consumer)
+	dl.Header.Set(HeaderDLQSequence, strconv.FormatUint(meta.Sequence.Stream, 10))
+	dl.Header.Set(HeaderDLQDeliveries, strconv.FormatUint(meta.NumDelivered, 10))
+	dl.Header.Set(HeaderDLQError, cause.Error())
+	dl.Header.Set(HeaderDLQTime, time.Now().Format(time.RFC3339))
+	// 同一条消息重复进入死信队列时去重（如发布成功但 Term 失败）
+	dl.Header.Set(jetstream.MsgIDHeader, fmt.Sprintf("dlq-%s-%s-%d", meta.Stream, meta.Consumer, meta.Sequence.Stream))
+	if c.js == nil {
+		return errJetStreamDisabled
+	}
+	_, err = c.js.PublishMsg(ctx, dl)
+	return
+}
+
+// DeadLetters 从 startSeq 开始查看死信流中的消息，最多 limit 条，startSeq 为 0 时从头开始
+func (c *Client) DeadLetters(ctx context.Context, stream string, startSeq uint64, limit int) (letters []DeadLetter, err error) {
+	if c.js == nil {
+		return nil, errJetStreamDisabled
+	}
+	s, err := c.js.Stream(ctx, stream)
+	if err != nil {
+		return
+	}
+	return deadLetters(ctx, s, startSeq, limit)
+}
+
+// deadLetters 从 startSeq 开始读取最多 limit 条死信，limit 小于等于 0 时读取到末尾
+func deadLetters(ctx context.Context, s jetstream.Stream, startSeq uint64, limit int) (letters []DeadLetter, err error) {
+	seq := max(startSeq, 1)
+	for limit <= 0 || len(letters) < limit {
+		// 按 subject 获取 seq 之后的下一条消息，跳过已删除的序号
+		raw, e := s.GetMsg(ctx, seq, jetstream.WithGetMsgSubject(">"))
+		if errors.Is(e, jetstream.ErrMsgNotFound) {
+			break
+		}
+		if e != nil {
+			return letters, e
+		}
+		letters = append(letters, parseDeadLetter(raw))
+		seq = raw.Sequence + 1
+	}
+	return
+}
+
+// ReplayDeadLetter 将死信重新发布到原消息的 subject，成功后从死信流中删除
+func (c *Client) ReplayDeadLetter(ctx context.Context, stream string, seq uint64) (err error) {
+	if c.js == nil {
+		return errJetStreamDisabled
+	}
+	s, err := c.js.Stream(ctx, stream)
+	if err != nil {
+		return
+	}
+	raw, err := s.GetMsg(ctx, seq)
+	if err != nil {
+		return
+	}
+	return c.replay(ctx, s, parseDeadLetter(raw))
+}
+
+// ReplayDeadLetters 重放死信流中 filter 返回 true 的消息，filter 为 nil 时重放所有消息，返回重放的消息数。
+// 分页读取，每页重放后再读取下一页；只重放开始时已有的死信，重放后再次失败进入死信流的消息留到下次
+func (c *Client) ReplayDeadLetters(ctx context.Context, stream string, filter func(DeadLetter) bool) (n int, err error) {
+	if c.js == nil {
+		return 0, errJetStreamDisabled
+	}
+	s, err := c.js.Stream(ctx, stream)
+	if err != nil {
+		return
+	}
+	last := s.CachedInfo().State.LastSeq
+	for seq := uint64(1); seq <= last; {
+		letters, e := deadLetters(ctx, s, seq, deadLetterPageSize)
+		if e != nil {
+			return n, e
+		}
+		if len(letters) == 0 {
+			break
+		}
+		for _, dl := range letters {
+			if dl.Sequence > last {
+				return
+			}
+			if filter != nil && !filter(dl) {
+				continue
+			}
+			if err = c.replay(ctx, s, dl); err != nil {
+				return
+			}
+			n++
+		}
+		seq = letters[len(letters)-1].Sequence + 1
+	}
+	return
+}
+
+func (c *Client) replay(ctx context.Context, s jetstream.Stream, dl DeadLetter) (err error) {
+	if dl.Subject == "" {
+		return fmt.Errorf("natscli: message %d is not a dead letter", dl.Sequence)
+	}
+	msg := nats.NewMsg(dl.Subject)
+	msg.Data = dl.Data
+	for k, v := range dl.Header {
+		// 去掉死信信息和原消息 ID，原消息 ID 在去重窗口内会导致重放的消息被丢弃
+		if strings.HasPrefix(k, "Dlq-") || k == jetstream.MsgIDHeader {
+			continue
+		}
+		msg.Header[k] = v
+	}
+	if _, err = c.js.PublishMsg(ctx, msg); err != nil {
+		return
+	}
+	if err = s.DeleteMsg(ctx, dl.Sequence); err != nil {
+		return
+	}
+	zlog.Info().Ctx(ctx).Str("subj", dl.Subject).Uint64("seq", dl.Sequence).Msg("死信已重放")
+	return
+}
+
+func parseDeadLetter(raw *jetstream.RawStreamMsg) DeadLetter {
+	dl := DeadLetter{
+		Sequence: raw.Sequence,
+		Subject:  raw.Header.Get(HeaderDLQSubject),
+		Stream:   raw.Header.Get(HeaderDLQStream),
+		Consumer: raw.Header.Get(HeaderDLQConsumer),
+		Error:    raw.Header.Get(HeaderDLQError),
+		Header:   raw.Header,
+		Data:     raw.Data,
+	}
+	dl.OriginalSequence, _ = strconv.ParseUint(raw.Header.Get(HeaderDLQSequence), 10, 64)
+	dl.Deliveries, _ = strconv.ParseUint(raw.Header.Get(HeaderDLQDeliveries), 10, 64)
+	if dl.Time, _ = time.Parse(time.RFC3339, raw.Header.Get(HeaderDLQTime)); dl.Time.IsZero() {
+		dl.Time = raw.Time
+	}
+	return dl
+}
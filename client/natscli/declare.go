@@ -0,0 +1,316 @@
+package natscli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/chenparty/gog/zlog"
+	"github.com/goccy/go-yaml"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog"
+)
+
+// Spec JetStream 流和消费者的声明，启动时通过 Reconcile 与服务端同步
+type Spec struct {
+	Streams []StreamSpec `yaml:"streams"`
+}
+
+// StreamSpec 流的声明，YAML 字段名与 NATS 的 JSON 配置一致，如 max_age、num_replicas、durable_name、ack_wait
+type StreamSpec struct {
+	jetstream.StreamConfig `yaml:",inline"`
+
+	Consumers []jetstream.ConsumerConfig `yaml:"consumers"`
+}
+
+// Action 同步时对资源执行的操作
+type Action string
+
+const (
+	ActionCreate    Action = "create"    // 资源不存在，创建
+	ActionUpdate    Action = "update"    // 配置不一致且都可以修改，更新
+	ActionUnchanged Action = "unchanged" // 配置一致
+	ActionConflict  Action = "conflict"  // 存在不能修改的字段不一致，需要人工处理（如删除后重建）
+)
+
+// FieldDiff 字段差异，Field 为 JSON 字段名
+type FieldDiff struct {
+	Field     string
+	Current   any
+	Desired   any
+	Immutable bool // 创建后不能修改
+}
+
+func (d FieldDiff) String() string {
+	s := fmt.Sprintf("%s: %v -> %v", d.Field, d.Current, d.Desired)
+	if d.Immutable {
+		s += " (immutable)"
+	}
+	return s
+}
+
+// ResourceChange 单个流或消费者的同步结果，Consumer 为空时表示流
+type ResourceChange struct {
+	Stream   string
+	Consumer string
+	Action   Action
+	Diffs    []FieldDiff
+}
+
+func (c ResourceChange) String() string {
+	name := "stream " + c.Stream
+	if c.Consumer != "" {
+		name = "consumer " + c.Stream + "/" + c.Consumer
+	}
+	if len(c.Diffs) == 0 {
+		return fmt.Sprintf("%s %s", c.Action, name)
+	}
+	diffs := make([]string, 0, len(c.Diffs))
+	for _, d := range c.Diffs {
+		diffs = append(diffs, d.String())
+	}
+	return fmt.Sprintf("%s %s: %s", c.Action, name, strings.Join(diffs, ", "))
+}
+
+// ReconcileReport 同步报告
+type ReconcileReport struct {
+	DryRun  bool
+	Changes []ResourceChange
+}
+
+// Conflicts 存在不能修改的字段不一致的资源
+func (r ReconcileReport) Conflicts() (conflicts []ResourceChange) {
+	for _, c := range r.Changes {
+		if c.Action == ActionConflict {
+			conflicts = append(conflicts, c)
+		}
+	}
+	return
+}
+
+func (r ReconcileReport) String() string {
+	lines := make([]string, 0, len(r.Changes))
+	for _, c := range r.Changes {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+type ReconcileOptions struct {
+	DryRun bool // 只比较差异，不修改服务端
+}
+
+type ReconcileOption func(*ReconcileOptions)
+
+// WithDryRun 只比较差异，不创建或更新资源
+func WithDryRun(dryRun bool) ReconcileOption {
+	return func(options *ReconcileOptions) {
+		options.DryRun = dryRun
+	}
+}
+
+// 创建后不能修改的字段
+var (
+	immutableStreamFields   = map[string]bool{"name": true, "storage": true, "retention": true, "max_consumers": true, "mirror": true}
+	immutableConsumerFields = map[string]bool{"deliver_policy": true, "opt_start_seq": true, "opt_start_time": true, "ack_policy": true, "replay_policy": true, "max_waiting": true, "mem_storage": true}
+)
+
+// LoadSpec 从 YAML 文件加载声明
+func LoadSpec(file string) (spec Spec, err error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return spec, fmt.Errorf("natscli: %w", err)
+	}
+	return ParseSpec(data)
+}
+
+// ParseSpec 解析 YAML 格式的声明，枚举和时长使用 NATS 的写法，如 retention: workqueue、storage: file、ack_wait: 30s
+func ParseSpec(data []byte) (spec Spec, err error) {
+	if err = yaml.UnmarshalWithOptions(data, &spec, yaml.UseJSONUnmarshaler()); err != nil {
+		return spec, fmt.Errorf("natscli: parse spec: %w", err)
+	}
+	return
+}
+
+// Reconcile 使用默认实例同步声明
+func Reconcile(ctx context.Context, spec Spec, options ...ReconcileOption) (ReconcileReport, error) {
+	return Default().Reconcile(ctx, spec, options...)
+}
+
+// Reconcile 按声明创建缺少的流和消费者，更新可修改的字段；存在不能修改的字段不一致时不做修改，在报告中标记为冲突。
+// 声明中未设置（零值）的字段保持服务端的当前值，因此不能通过声明将布尔字段改为 false。
+// 单个资源失败不影响其它资源，返回所有错误
+func (c *Client) Reconcile(ctx context.Context, spec Spec, options ...ReconcileOption) (report ReconcileReport, err error) {
+	var opts ReconcileOptions
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	report.DryRun = opts.DryRun
+	if c.js == nil {
+		return report, errJetStreamDisabled
+	}
+	var errs []error
+	for _, s := range spec.Streams {
+		change, e := c.reconcileStream(ctx, s.StreamConfig, opts.DryRun)
+		if e != nil {
+			errs = append(errs, fmt.Errorf("natscli: stream %s: %w", s.Name, e))
+			continue
+		}
+		report.Changes = append(report.Changes, change)
+		logChange(change, opts.DryRun)
+		for _, cc := range s.Consumers {
+			// 流在 dry-run 模式下未创建时，消费者一定需要创建
+			if change.Action == ActionCreate && opts.DryRun {
+				report.Changes = append(report.Changes, ResourceChange{Stream: s.Name, Consumer: consumerName(cc), Action: ActionCreate})
+				continue
+			}
+			cchange, e := c.reconcileConsumer(ctx, s.Name, cc, opts.DryRun)
+			if e != nil {
+				errs = append(errs, fmt.Errorf("natscli: consumer %s/%s: %w", s.Name, consumerName(cc), e))
+				continue
+			}
+			report.Changes = append(report.Changes, cchange)
+			logChange(cchange, opts.DryRun)
+		}
+	}
+	return report, errors.Join(errs...)
+}
+
+func (c *Client) reconcileStream(ctx context.Context, desired jetstream.StreamConfig, dryRun bool) (change ResourceChange, err error) {
+	change.Stream = desired.Name
+	if desired.Name == "" {
+		return change, errors.New("name is required")
+	}
+	stream, err := c.js.Stream(ctx, desired.Name)
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		change.Action = ActionCreate
+		if !dryRun {
+			_, err = c.js.CreateStream(ctx, desired)
+		} else {
+			err = nil
+		}
+		return
+	}
+	if err != nil {
+		return
+	}
+	current := stream.CachedInfo().Config
+	merged, diffs := mergeConfig(current, desired, immutableStreamFields)
+	change.Action, change.Diffs = actionOf(diffs), diffs
+	if change.Action == ActionUpdate && !dryRun {
+		_, err = c.js.UpdateStream(ctx, merged)
+	}
+	return
+}
+
+func (c *Client) reconcileConsumer(ctx context.Context, stream string, desired jetstream.ConsumerConfig, dryRun bool) (change ResourceChange, err error) {
+	name := consumerName(desired)
+	change.Stream, change.Consumer = stream, name
+	if name == "" {
+		return change, errors.New("durable_name is required")
+	}
+	cons, err := c.js.Consumer(ctx, stream, name)
+	if errors.Is(err, jetstream.ErrConsumerNotFound) {
+		change.Action = ActionCreate
+		if !dryRun {
+			_, err = c.js.CreateConsumer(ctx, stream, desired)
+		} else {
+			err = nil
+		}
+		return
+	}
+	if err != nil {
+		return
+	}
+	current := cons.CachedInfo().Config
+	merged, diffs := mergeConfig(current, desired, immutableConsumerFields)
+	change.Action, change.Diffs = actionOf(diffs), diffs
+	if change.Action == ActionUpdate && !dryRun {
+		_, err = c.js.UpdateConsumer(ctx, stream, merged)
+	}
+	return
+}
+
+func consumerName(cfg jetstream.ConsumerConfig) string {
+	if cfg.Durable != "" {
+		return cfg.Durable
+	}
+	return cfg.Name
+}
+
+func actionOf(diffs []FieldDiff) Action {
+	if len(diffs) == 0 {
+		return ActionUnchanged
+	}
+	for _, d := range diffs {
+		if d.Immutable {
+			return ActionConflict
+		}
+	}
+	return ActionUpdate
+}
+
+// mergeConfig 将 desired 中的非零值字段覆盖到 current 上，返回合并后的配置和差异；
+// map 字段（如 metadata）只比较和覆盖 desired 中的 key，服务端自动添加的 key 保持不变
+func mergeConfig[T any](current, desired T, immutable map[string]bool) (merged T, diffs []FieldDiff) {
+	merged = current
+	mv := reflect.ValueOf(&merged).Elem()
+	cv := reflect.ValueOf(current)
+	dv := reflect.ValueOf(desired)
+	t := cv.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		d := dv.Field(i)
+		if d.IsZero() {
+			continue
+		}
+		cur := cv.Field(i)
+		if d.Kind() == reflect.Map {
+			m := reflect.MakeMap(cur.Type())
+			for _, k := range cur.MapKeys() {
+				m.SetMapIndex(k, cur.MapIndex(k))
+			}
+			changed := false
+			for _, k := range d.MapKeys() {
+				if v := cur.MapIndex(k); !v.IsValid() || !reflect.DeepEqual(v.Interface(), d.MapIndex(k).Interface()) {
+					changed = true
+				}
+				m.SetMapIndex(k, d.MapIndex(k))
+			}
+			if changed {
+				diffs = append(diffs, FieldDiff{Field: name, Current: cur.Interface(), Desired: d.Interface(), Immutable: immutable[name]})
+				mv.Field(i).Set(m)
+			}
+			continue
+		}
+		if reflect.DeepEqual(cur.Interface(), d.Interface()) {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{Field: name, Current: cur.Interface(), Desired: d.Interface(), Immutable: immutable[name]})
+		mv.Field(i).Set(d)
+	}
+	return
+}
+
+func logChange(c ResourceChange, dryRun bool) {
+	var e *zerolog.Event
+	switch c.Action {
+	case ActionUnchanged:
+		e = zlog.Debug()
+	case ActionConflict:
+		e = zlog.Warn()
+	default:
+		e = zlog.Info()
+	}
+	e.Str("stream", c.Stream).Str("consumer", c.Consumer).Str("action", string(c.Action)).
+		Bool("dry_run", dryRun).Str("diff", c.String()).Msg("JetStream资源同步")
+}
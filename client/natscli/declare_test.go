@@ -0,0 +1,177 @@
+package natscli_test
+
+import (
+	"testing"
+
+	"github.com/chenparty/gog/client/natscli"
+	"github.com/chenparty/gog/gogtest"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const specYAML = `
+streams:
+  - name: ORDERS
+    subjects: ["orders.>"]
+    storage: file
+    max_msgs: 100
+    consumers:
+      - durable_name: billing
+        ack_policy: explicit
+        max_deliver: 5
+`
+
+func parseSpec(t *testing.T, data string) natscli.Spec {
+	t.Helper()
+	spec, err := natscli.ParseSpec([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func actions(report natscli.ReconcileReport) (actions []natscli.Action) {
+	for _, c := range report.Changes {
+		actions = append(actions, c.Action)
+	}
+	return
+}
+
+func TestReconcileCreate(t *testing.T) {
+	c, _ := gogtest.NATS(t)
+	ctx := t.Context()
+	report, err := c.Reconcile(ctx, parseSpec(t, specYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(report); len(got) != 2 || got[0] != natscli.ActionCreate || got[1] != natscli.ActionCreate {
+		t.Fatalf("unexpected actions: %v", got)
+	}
+	cons, err := c.JS().Consumer(ctx, "ORDERS", "billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg := cons.CachedInfo().Config; cfg.MaxDeliver != 5 || cfg.AckPolicy != jetstream.AckExplicitPolicy {
+		t.Fatalf("unexpected consumer config: %+v", cfg)
+	}
+
+	// 再次同步时没有变化
+	report, err = c.Reconcile(ctx, parseSpec(t, specYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(report); len(got) != 2 || got[0] != natscli.ActionUnchanged || got[1] != natscli.ActionUnchanged {
+		t.Fatalf("unexpected actions: %v\n%s", got, report)
+	}
+}
+
+func TestReconcileUpdate(t *testing.T) {
+	c, _ := gogtest.NATS(t)
+	ctx := t.Context()
+	if _, err := c.Reconcile(ctx, parseSpec(t, specYAML)); err != nil {
+		t.Fatal(err)
+	}
+	spec := parseSpec(t, specYAML)
+	spec.Streams[0].MaxMsgs = 200
+	spec.Streams[0].Consumers[0].MaxDeliver = 10
+	report, err := c.Reconcile(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(report); len(got) != 2 || got[0] != natscli.ActionUpdate || got[1] != natscli.ActionUpdate {
+		t.Fatalf("unexpected actions: %v\n%s", got, report)
+	}
+	if d := report.Changes[0].Diffs; len(d) != 1 || d[0].Field != "max_msgs" || d[0].Immutable {
+		t.Fatalf("unexpected stream diffs: %v", d)
+	}
+	stream, err := c.JS().Stream(ctx, "ORDERS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 声明中未设置的字段保持服务端的当前值
+	if cfg := stream.CachedInfo().Config; cfg.MaxMsgs != 200 || len(cfg.Subjects) != 1 {
+		t.Fatalf("unexpected stream config: %+v", cfg)
+	}
+	cons, err := c.JS().Consumer(ctx, "ORDERS", "billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg := cons.CachedInfo().Config; cfg.MaxDeliver != 10 {
+		t.Fatalf("unexpected max_deliver: %d", cfg.MaxDeliver)
+	}
+}
+
+func TestReconcileConflict(t *testing.T) {
+	c, _ := gogtest.NATS(t)
+	ctx := t.Context()
+	if _, err := c.Reconcile(ctx, parseSpec(t, specYAML)); err != nil {
+		t.Fatal(err)
+	}
+	spec := parseSpec(t, specYAML)
+	spec.Streams[0].Storage = jetstream.MemoryStorage
+	spec.Streams[0].MaxMsgs = 300
+	report, err := c.Reconcile(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflicts := report.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].Stream != "ORDERS" || conflicts[0].Consumer != "" {
+		t.Fatalf("unexpected conflicts: %v", conflicts)
+	}
+	// 存在冲突时不修改任何字段
+	stream, err := c.JS().Stream(ctx, "ORDERS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg := stream.CachedInfo().Config; cfg.Storage != jetstream.FileStorage || cfg.MaxMsgs != 100 {
+		t.Fatalf("stream modified on conflict: %+v", cfg)
+	}
+}
+
+func TestReconcileDryRun(t *testing.T) {
+	c, _ := gogtest.NATS(t)
+	ctx := t.Context()
+	report, err := c.Reconcile(ctx, parseSpec(t, specYAML), natscli.WithDryRun(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun {
+		t.Fatal("report is not marked as dry run")
+	}
+	if got := actions(report); len(got) != 2 || got[0] != natscli.ActionCreate || got[1] != natscli.ActionCreate {
+		t.Fatalf("unexpected actions: %v", got)
+	}
+	if _, err = c.JS().Stream(ctx, "ORDERS"); err == nil {
+		t.Fatal("stream created in dry run")
+	}
+
+	if _, err = c.Reconcile(ctx, parseSpec(t, specYAML)); err != nil {
+		t.Fatal(err)
+	}
+	spec := parseSpec(t, specYAML)
+	spec.Streams[0].MaxMsgs = 200
+	if report, err = c.Reconcile(ctx, spec, natscli.WithDryRun(true)); err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(report); got[0] != natscli.ActionUpdate {
+		t.Fatalf("unexpected actions: %v", got)
+	}
+	stream, err := c.JS().Stream(ctx, "ORDERS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg := stream.CachedInfo().Config; cfg.MaxMsgs != 100 {
+		t.Fatalf("stream updated in dry run: %d", cfg.MaxMsgs)
+	}
+}
+
+func TestReconcileWithoutJetStream(t *testing.T) {
+	_, ns := gogtest.NATS(t)
+	c, err := natscli.New("no-jetstream", []string{ns.ClientURL()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err = c.Reconcile(t.Context(), parseSpec(t, specYAML)); err == nil {
+		t.Fatal("expected error when JetStream is not enabled")
+	}
+}
@@ -1,30 +1,185 @@
 package natscli
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chenparty/gog/metrics"
+	"github.com/chenparty/gog/zlog"
+	"github.com/chenparty/gog/zlog/ginplugin"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
-var jsc nats.JetStreamContext
+// errJetStreamDisabled 创建实例时未启用 JetStream（WithJetStream）
+var errJetStreamDisabled = errors.New("natscli: jetstream is not enabled")
 
-func newJetStreamContext() (err error) {
+func newJetStreamContext(nc *nats.Conn) (jsc nats.JetStreamContext, js jetstream.JetStream, err error) {
 	jsc, err = nc.JetStream(nats.PublishAsyncMaxPending(256))
+	if err != nil {
+		return
+	}
+	js, err = jetstream.New(nc, jetstream.WithPublishAsyncMaxPending(256))
 	return
 }
 
-// AddStream 添加流（不会重复创建，也不会删除再创建）
+// JsMsgHandler 处理 JetStream 消息。返回 nil 时确认消息，返回错误时消息重新投递（Nak），
+// 使用 NakWithDelay、RetryAfter 延迟重试，Term、Terminate 放弃消息不再投递；
+// 在 handler 中已经调用过 Ack/Nak/Term 时不会重复确认。处理时间较长时调用 msg.InProgress 重置 AckWait
+type JsMsgHandler func(ctx context.Context, msg jetstream.Msg) error
+
+// retryAfterError 延迟重试的错误
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// terminateError 放弃消息的错误
+type terminateError struct{ err error }
+
+func (e *terminateError) Error() string { return e.err.Error() }
+func (e *terminateError) Unwrap() error { return e.err }
+
+// RetryAfter 包装 JsMsgHandler 返回的错误，消息在 delay 后重新投递
+func RetryAfter(err error, delay time.Duration) error {
+	return &retryAfterError{err: err, delay: delay}
+}
+
+// Terminate 包装 JsMsgHandler 返回的错误，消息不再投递，如消息格式错误
+func Terminate(err error) error {
+	return &terminateError{err: err}
+}
+
+// ConsumeOptions 消费选项
+type ConsumeOptions struct {
+	// MaxMessages 客户端缓存的最大消息数，默认 500；消费者的 MaxAckPending 限制所有实例处理中的消息总数
+	MaxMessages int
+	// Expires 单次拉取请求的超时时间，默认 30 秒
+	Expires time.Duration
+}
+
+type ConsumeOption func(*ConsumeOptions)
+
+// WithMaxMessages 设置客户端缓存的最大消息数
+func WithMaxMessages(n int) ConsumeOption {
+	return func(options *ConsumeOptions) {
+		options.MaxMessages = n
+	}
+}
+
+// WithPullExpires 设置单次拉取请求的超时时间
+func WithPullExpires(expires time.Duration) ConsumeOption {
+	return func(options *ConsumeOptions) {
+		options.Expires = expires
+	}
+}
+
+// AddStream 添加流（不会重复创建，也不会删除再创建），流使用 WorkQueuePolicy，需要其它配置时使用 CreateOrUpdateStream
 func AddStream(streamName string, subjects []string) error {
+	return Default().AddStream(streamName, subjects)
+}
+
+// DelStream 删除流
+func DelStream(streamName string) (err error) {
+	return Default().DelStream(streamName)
+}
+
+// AddConsumer 添加流消费者
+//
+// Deprecated: 使用 CreateOrUpdateConsumer
+func AddConsumer(streamName string, config *nats.ConsumerConfig) (err error) {
+	return Default().AddConsumer(streamName, config)
+}
+
+// DelConsumer 删除流消费者
+func DelConsumer(stream, consumer string) (err error) {
+	return Default().DelConsumer(stream, consumer)
+}
+
+// JsPub 发布流消息
+func JsPub(subj string, data []byte) (err error) {
+	return Default().JsPub(subj, data)
+}
+
+// JsSub 订阅流消息
+//
+// Deprecated: 创建的是临时的推送消费者，服务重启后从头或从最新消息开始消费，使用 CreateOrUpdateConsumer 和 Consume
+func JsSub(subj string, handler nats.MsgHandler) (err error) {
+	return Default().JsSub(subj, handler)
+}
+
+// JsQueueSubscribe 队列方式订阅流消息(分布式场景使用)
+//
+// Deprecated: 使用 CreateOrUpdateConsumer 和 Consume，多个实例消费同一个持久化拉取消费者即为队列方式
+func JsQueueSubscribe(subject, queueName string, handler nats.MsgHandler) (err error) {
+	return Default().JsQueueSubscribe(subject, queueName, handler)
+}
+
+// CreateOrUpdateStream 使用默认实例创建或更新流
+func CreateOrUpdateStream(ctx context.Context, cfg jetstream.StreamConfig) (jetstream.Stream, error) {
+	return Default().CreateOrUpdateStream(ctx, cfg)
+}
+
+// CreateOrUpdateConsumer 使用默认实例创建或更新持久化拉取消费者
+func CreateOrUpdateConsumer(ctx context.Context, stream string, cfg jetstream.ConsumerConfig) (jetstream.Consumer, error) {
+	return Default().CreateOrUpdateConsumer(ctx, stream, cfg)
+}
+
+// JsPublish 使用默认实例发布流消息
+func JsPublish(ctx context.Context, subj string, data []byte, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	return Default().JsPublish(ctx, subj, data, opts...)
+}
+
+// JsPublishMsg 使用默认实例发布带消息头的流消息
+func JsPublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	return Default().JsPublishMsg(ctx, msg, opts...)
+}
+
+// Consume 使用默认实例持续消费
+func Consume(ctx context.Context, stream, consumer string, handler JsMsgHandler, options ...ConsumeOption) (jetstream.ConsumeContext, error) {
+	return Default().Consume(ctx, stream, consumer, handler, options...)
+}
+
+// Fetch 使用默认实例拉取一批消息
+func Fetch(ctx context.Context, stream, consumer string, batch int, handler JsMsgHandler) (n int, err error) {
+	return Default().Fetch(ctx, stream, consumer, batch, handler)
+}
+
+// JetStream 获取旧版的 JetStream 上下文，未启用 JetStream 时返回 nil
+//
+// Deprecated: 使用 JS 获取新版 jetstream API
+func (c *Client) JetStream() nats.JetStreamContext {
+	return c.jsc
+}
+
+// JS 获取新版的 jetstream API，未启用 JetStream 时返回 nil
+func (c *Client) JS() jetstream.JetStream {
+	return c.js
+}
+
+// AddStream 添加流（不会重复创建，也不会删除再创建），流使用 WorkQueuePolicy，需要其它配置时使用 CreateOrUpdateStream
+func (c *Client) AddStream(streamName string, subjects []string) error {
+	if c.js == nil {
+		return errJetStreamDisabled
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 	// 按名称查找流信息
-	_, err := jsc.StreamInfo(streamName)
+	_, err := c.js.Stream(ctx, streamName)
 	if err != nil {
 		// 流不存在
-		if errors.Is(err, nats.ErrStreamNotFound) {
+		if errors.Is(err, jetstream.ErrStreamNotFound) {
 			// 创建流
-			_, err = jsc.AddStream(&nats.StreamConfig{
+			_, err = c.js.CreateStream(ctx, jetstream.StreamConfig{
 				Name:      streamName,
 				Subjects:  subjects,
-				NoAck:     false, // false:自动ack | true:手动ack
-				Retention: nats.WorkQueuePolicy,
+				NoAck:     false, // false:服务端确认每条发布的消息 | true:发布时不等待确认
+				Retention: jetstream.WorkQueuePolicy,
 			})
 			return err
 		}
@@ -36,40 +191,212 @@ func AddStream(streamName string, subjects []string) error {
 }
 
 // DelStream 删除流
-func DelStream(streamName string) (err error) {
-	err = jsc.DeleteStream(streamName)
+func (c *Client) DelStream(streamName string) (err error) {
+	if c.js == nil {
+		return errJetStreamDisabled
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = c.js.DeleteStream(ctx, streamName)
 	return
 }
 
 // AddConsumer 添加流消费者
-func AddConsumer(streamName string, config *nats.ConsumerConfig) (err error) {
-	_, err = jsc.AddConsumer(streamName, config)
+//
+// Deprecated: 使用 CreateOrUpdateConsumer
+func (c *Client) AddConsumer(streamName string, config *nats.ConsumerConfig) (err error) {
+	if c.jsc == nil {
+		return errJetStreamDisabled
+	}
+	_, err = c.jsc.AddConsumer(streamName, config)
 	return
 }
 
 // DelConsumer 删除流消费者
-func DelConsumer(stream, consumer string) (err error) {
-	err = jsc.DeleteConsumer(stream, consumer)
+func (c *Client) DelConsumer(stream, consumer string) (err error) {
+	if c.js == nil {
+		return errJetStreamDisabled
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = c.js.DeleteConsumer(ctx, stream, consumer)
 	return
 }
 
 // JsPub 发布流消息
-func JsPub(subj string, data []byte) (err error) {
-	_, err = jsc.Publish(subj, data)
+func (c *Client) JsPub(subj string, data []byte) (err error) {
+	_, err = c.JsPublish(context.Background(), subj, data)
 	return
 }
 
 // JsSub 订阅流消息
-func JsSub(subj string, handler nats.MsgHandler) (err error) {
-	_, err = jsc.Subscribe(subj, handler)
+//
+// Deprecated: 创建的是临时的推送消费者，服务重启后从头或从最新消息开始消费，使用 CreateOrUpdateConsumer 和 Consume
+func (c *Client) JsSub(subj string, handler nats.MsgHandler) (err error) {
+	_, err = c.Subscribe(subj, handler, WithJsSub())
+	return
+}
+
+// JsQueueSubscribe 队列方式订阅流消息(分布式场景使用)
+//
+// Deprecated: 使用 CreateOrUpdateConsumer 和 Consume，多个实例消费同一个持久化拉取消费者即为队列方式
+func (c *Client) JsQueueSubscribe(subject, queueName string, handler nats.MsgHandler) (err error) {
+	_, err = c.Subscribe(subject, handler, WithQueue(queueName), WithJsSub())
+	return
+}
+
+// CreateOrUpdateStream 创建或更新流，保留策略、容量限制、副本数、存储类型等完全由 cfg 决定，
+// 已存在的流按 cfg 更新，不能修改的字段（如 Storage、Retention）不一致时返回错误
+func (c *Client) CreateOrUpdateStream(ctx context.Context, cfg jetstream.StreamConfig) (jetstream.Stream, error) {
+	if c.js == nil {
+		return nil, errJetStreamDisabled
+	}
+	return c.js.CreateOrUpdateStream(ctx, cfg)
+}
+
+// CreateOrUpdateConsumer 创建或更新持久化拉取消费者，cfg.Durable 必须设置。
+// AckPolicy 未设置时为 AckExplicitPolicy；MaxAckPending 为所有实例处理中（未确认）的最大消息数，
+// AckWait 为确认超时时间，超时后消息重新投递
+func (c *Client) CreateOrUpdateConsumer(ctx context.Context, stream string, cfg jetstream.ConsumerConfig) (jetstream.Consumer, error) {
+	if c.js == nil {
+		return nil, errJetStreamDisabled
+	}
+	if cfg.Durable == "" {
+		return nil, errors.New("natscli: consumer durable name is required")
+	}
+	return c.js.CreateOrUpdateConsumer(ctx, stream, cfg)
+}
+
+// JsPublish 发布流消息并等待服务端确认，opts 可设置消息 ID（去重）、期望的流等
+func (c *Client) JsPublish(ctx context.Context, subj string, data []byte, opts ...jetstream.PublishOpt) (ack *jetstream.PubAck, err error) {
+	if c.js == nil {
+		return nil, errJetStreamDisabled
+	}
+	ack, err = c.js.Publish(ctx, subj, data, opts...)
+	metrics.IncNATSPublish(err)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("subj", subj).Msg("js.Publish")
+	}
+	return
+}
+
+// JsPublishMsg 发布带消息头的流消息并等待服务端确认，消息头 Nats-Msg-Id 用于去重
+func (c *Client) JsPublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (ack *jetstream.PubAck, err error) {
+	if c.js == nil {
+		return nil, errJetStreamDisabled
+	}
+	ack, err = c.js.PublishMsg(ctx, msg, opts...)
+	metrics.IncNATSPublish(err)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("subj", msg.Subject).Msg("js.PublishMsg")
+	}
+	return
+}
+
+// Consume 持续消费持久化拉取消费者的消息，直到调用返回值的 Stop 或 ctx 取消；
+// handler 按消息顺序依次调用，多个实例消费同一个消费者时消息在实例间分配
+func (c *Client) Consume(ctx context.Context, stream, consumer string, handler JsMsgHandler, options ...ConsumeOption) (cc jetstream.ConsumeContext, err error) {
+	if c.js == nil {
+		return nil, errJetStreamDisabled
+	}
+	opts := ConsumeOptions{MaxMessages: 500, Expires: 30 * time.Second}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	cons, err := c.js.Consumer(ctx, stream, consumer)
+	if err != nil {
+		return
+	}
+	cc, err = cons.Consume(func(msg jetstream.Msg) {
+		handleJsMsg(ctx, stream, consumer, msg, handler)
+	},
+		jetstream.PullMaxMessages(opts.MaxMessages),
+		jetstream.PullExpiry(opts.Expires),
+		jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+			zlog.Warn().Err(err).Str("stream", stream).Str("consumer", consumer).Msg("JetStream消费出错")
+		}),
+	)
 	if err != nil {
 		return
 	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			cc.Stop()
+		case <-cc.Closed():
+		}
+	}()
 	return
 }
 
-// JsQueueSubscribe 队列方式订阅流消息(分布式场景使用)
-func JsQueueSubscribe(subject, queueName string, handler nats.MsgHandler) (err error) {
-	_, err = jsc.QueueSubscribe(subject, queueName, handler)
+// Fetch 拉取并处理一批消息，最多 batch 条，等待时间由 ctx 的 deadline 决定（未设置时为 30 秒），返回处理的消息数
+func (c *Client) Fetch(ctx context.Context, stream, consumer string, batch int, handler JsMsgHandler) (n int, err error) {
+	if c.js == nil {
+		return 0, errJetStreamDisabled
+	}
+	cons, err := c.js.Consumer(ctx, stream, consumer)
+	if err != nil {
+		return
+	}
+	wait := 30 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		if wait = time.Until(deadline); wait <= 0 {
+			return 0, context.DeadlineExceeded
+		}
+	}
+	msgs, err := cons.Fetch(batch, jetstream.FetchMaxWait(wait))
+	if err != nil {
+		return
+	}
+	for msg := range msgs.Messages() {
+		handleJsMsg(ctx, stream, consumer, msg, handler)
+		n++
+	}
+	err = msgs.Error()
+	if errors.Is(err, nats.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		err = nil
+	}
 	return
 }
+
+// handleJsMsg 调用 handler 并按返回值确认消息，handler panic 时消息重新投递
+func handleJsMsg(ctx context.Context, stream, consumer string, msg jetstream.Msg, handler JsMsgHandler) {
+	// 投递主题可能包含 ID 等无界的值，使用流和消费者作为指标标签
+	metrics.IncNATSReceive(stream + "/" + consumer)
+	// 消息头 Z-Request-ID 作为 trace_id，与 NewTraceContextWithID 一致，但保留 ctx 的取消和超时
+	traceID := msg.Headers().Get(ginplugin.HeaderRequestID)
+	if traceID == "" {
+		traceID = zlog.NewTraceID()
+	}
+	ctx = zlog.ContextWithValue(ctx, traceID)
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return handler(ctx, msg)
+	}()
+
+	var ackErr error
+	var retry *retryAfterError
+	var term *terminateError
+	switch {
+	case err == nil:
+		ackErr = msg.Ack()
+	case errors.As(err, &term):
+		ackErr = msg.TermWithReason(err.Error())
+	case errors.As(err, &retry):
+		ackErr = msg.NakWithDelay(retry.delay)
+	default:
+		ackErr = msg.Nak()
+	}
+	if err != nil {
+		zlog.Warn().Ctx(ctx).Err(err).Str("stream", stream).Str("consumer", consumer).Str("subj", msg.Subject()).Msg("JetStream消息处理失败")
+	}
+	if ackErr != nil && !errors.Is(ackErr, jetstream.ErrMsgAlreadyAckd) {
+		zlog.Error().Ctx(ctx).Err(ackErr).Str("stream", stream).Str("consumer", consumer).Msg("JetStream消息确认失败")
+	}
+}
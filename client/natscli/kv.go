@@ -0,0 +1,353 @@
+package natscli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chenparty/gog/zlog"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// CreateOrUpdateKV 使用默认实例创建或更新 KV 桶
+func CreateOrUpdateKV(ctx context.Context, cfg jetstream.KeyValueConfig) (jetstream.KeyValue, error) {
+	return Default().CreateOrUpdateKV(ctx, cfg)
+}
+
+// DeleteKV 使用默认实例删除 KV 桶
+func DeleteKV(ctx context.Context, bucket string) error {
+	return Default().DeleteKV(ctx, bucket)
+}
+
+// KVGet 使用默认实例获取 key 的值
+func KVGet(ctx context.Context, bucket, key string) (entry jetstream.KeyValueEntry, isNotExist bool, err error) {
+	return Default().KVGet(ctx, bucket, key)
+}
+
+// KVPut 使用默认实例设置 key 的值
+func KVPut(ctx context.Context, bucket, key string, value []byte) (rev uint64, err error) {
+	return Default().KVPut(ctx, bucket, key, value)
+}
+
+// KVCreate 使用默认实例在 key 不存在时设置值
+func KVCreate(ctx context.Context, bucket, key string, value []byte) (rev uint64, err error) {
+	return Default().KVCreate(ctx, bucket, key, value)
+}
+
+// KVUpdate 使用默认实例按版本号更新 key 的值
+func KVUpdate(ctx context.Context, bucket, key string, value []byte, lastRev uint64) (rev uint64, err error) {
+	return Default().KVUpdate(ctx, bucket, key, value, lastRev)
+}
+
+// KVDelete 使用默认实例删除 key
+func KVDelete(ctx context.Context, bucket, key string, opts ...jetstream.KVDeleteOpt) error {
+	return Default().KVDelete(ctx, bucket, key, opts...)
+}
+
+// KVWatch 使用默认实例监听 key 的变化
+func KVWatch(ctx context.Context, bucket, keys string, handler func(ctx context.Context, entry jetstream.KeyValueEntry), opts ...jetstream.WatchOpt) error {
+	return Default().KVWatch(ctx, bucket, keys, handler, opts...)
+}
+
+// KVHistory 使用默认实例获取 key 的历史值
+func KVHistory(ctx context.Context, bucket, key string) ([]jetstream.KeyValueEntry, error) {
+	return Default().KVHistory(ctx, bucket, key)
+}
+
+// CreateOrUpdateKV 创建或更新 KV 桶，cfg.History 为每个 key 保留的历史版本数（最大 64）
+func (c *Client) CreateOrUpdateKV(ctx context.Context, cfg jetstream.KeyValueConfig) (kv jetstream.KeyValue, err error) {
+	if c.js == nil {
+		return nil, errJetStreamDisabled
+	}
+	kv, err = c.js.CreateOrUpdateKeyValue(ctx, cfg)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", cfg.Bucket).Msg("js.CreateOrUpdateKeyValue")
+		return
+	}
+	c.kvs.Store(cfg.Bucket, kv)
+	return
+}
+
+// DeleteKV 删除 KV 桶及其中所有的 key
+func (c *Client) DeleteKV(ctx context.Context, bucket string) (err error) {
+	if c.js == nil {
+		return errJetStreamDisabled
+	}
+	c.kvs.Delete(bucket)
+	if err = c.js.DeleteKeyValue(ctx, bucket); err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Msg("js.DeleteKeyValue")
+	}
+	return
+}
+
+// KV 获取 KV 桶，结果会被缓存
+func (c *Client) KV(ctx context.Context, bucket string) (kv jetstream.KeyValue, err error) {
+	if c.js == nil {
+		return nil, errJetStreamDisabled
+	}
+	if v, ok := c.kvs.Load(bucket); ok {
+		return v.(jetstream.KeyValue), nil
+	}
+	kv, err = c.js.KeyValue(ctx, bucket)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Msg("js.KeyValue")
+		return
+	}
+	c.kvs.Store(bucket, kv)
+	return
+}
+
+// KVGet 获取 key 的值，key 不存在或已删除时 isNotExist 为 true
+func (c *Client) KVGet(ctx context.Context, bucket, key string) (entry jetstream.KeyValueEntry, isNotExist bool, err error) {
+	kv, err := c.KV(ctx, bucket)
+	if err != nil {
+		return
+	}
+	entry, err = kv.Get(ctx, key)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return nil, true, nil
+	}
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("key", key).Msg("kv.Get")
+	}
+	return
+}
+
+// KVPut 设置 key 的值，返回新的版本号
+func (c *Client) KVPut(ctx context.Context, bucket, key string, value []byte) (rev uint64, err error) {
+	kv, err := c.KV(ctx, bucket)
+	if err != nil {
+		return
+	}
+	if rev, err = kv.Put(ctx, key, value); err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("key", key).Msg("kv.Put")
+	}
+	return
+}
+
+// KVCreate key 不存在（或已删除）时设置值，已存在时返回 jetstream.ErrKeyExists
+func (c *Client) KVCreate(ctx context.Context, bucket, key string, value []byte) (rev uint64, err error) {
+	kv, err := c.KV(ctx, bucket)
+	if err != nil {
+		return
+	}
+	if rev, err = kv.Create(ctx, key, value); err != nil && !errors.Is(err, jetstream.ErrKeyExists) {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("key", key).Msg("kv.Create")
+	}
+	return
+}
+
+// KVUpdate 当前版本号为 lastRev 时更新值（CAS），版本号不一致时返回 jetstream.ErrKeyExists，需要重新读取后再更新
+func (c *Client) KVUpdate(ctx context.Context, bucket, key string, value []byte, lastRev uint64) (rev uint64, err error) {
+	kv, err := c.KV(ctx, bucket)
+	if err != nil {
+		return
+	}
+	if rev, err = kv.Update(ctx, key, value, lastRev); err != nil && !errors.Is(err, jetstream.ErrKeyExists) {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("key", key).Msg("kv.Update")
+	}
+	return
+}
+
+// KVDelete 删除 key，历史值保留；opts 可以使用 jetstream.LastRevision 按版本号删除
+func (c *Client) KVDelete(ctx context.Context, bucket, key string, opts ...jetstream.KVDeleteOpt) (err error) {
+	kv, err := c.KV(ctx, bucket)
+	if err != nil {
+		return
+	}
+	if err = kv.Delete(ctx, key, opts...); err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("key", key).Msg("kv.Delete")
+	}
+	return
+}
+
+// KVWatch 监听 key 的变化直到 ctx 取消，keys 可以使用通配符（如 "flags.>" 监听前缀）；
+// 默认先回调所有 key 的当前值，entry.Operation() 为 jetstream.KeyValueDelete、KeyValuePurge 时表示删除
+func (c *Client) KVWatch(ctx context.Context, bucket, keys string, handler func(ctx context.Context, entry jetstream.KeyValueEntry), opts ...jetstream.WatchOpt) (err error) {
+	kv, err := c.KV(ctx, bucket)
+	if err != nil {
+		return
+	}
+	w, err := kv.Watch(ctx, keys, opts...)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("keys", keys).Msg("kv.Watch")
+		return
+	}
+	go func() {
+		defer w.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case entry, ok := <-w.Updates():
+				if !ok {
+					return
+				}
+				// nil 表示当前值已全部回调
+				if entry == nil {
+					continue
+				}
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							zlog.Error().Ctx(ctx).Str("bucket", bucket).Str("key", entry.Key()).Msg(fmt.Sprint("KV监听回调panic:", r))
+						}
+					}()
+					handler(ctx, entry)
+				}()
+			}
+		}
+	}()
+	return
+}
+
+// KVHistory 获取 key 的历史值，按版本号从旧到新排列，保留的数量由桶的 History 决定
+func (c *Client) KVHistory(ctx context.Context, bucket, key string) (entries []jetstream.KeyValueEntry, err error) {
+	kv, err := c.KV(ctx, bucket)
+	if err != nil {
+		return
+	}
+	entries, err = kv.History(ctx, key)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("key", key).Msg("kv.History")
+	}
+	return
+}
+
+// TypedKV 值为 T 的 KV 桶，T 为 []byte 和 string 时保存原始内容，其它类型使用 JSON
+type TypedKV[T any] struct {
+	c      *Client
+	bucket string
+}
+
+// KVEntry TypedKV 的值
+type KVEntry[T any] struct {
+	Key       string
+	Value     T // 删除时为零值
+	Revision  uint64
+	Operation jetstream.KeyValueOp
+	Created   time.Time
+}
+
+// NewTypedKV 创建值为 T 的 KV 桶，c 为 nil 时使用默认实例，如
+// flags := natscli.NewTypedKV[FeatureFlags](nil, "flags")
+func NewTypedKV[T any](c *Client, bucket string) *TypedKV[T] {
+	return &TypedKV[T]{c: c, bucket: bucket}
+}
+
+func (kv *TypedKV[T]) client() *Client {
+	if kv.c != nil {
+		return kv.c
+	}
+	return Default()
+}
+
+// Get 获取 key 的值和版本号
+func (kv *TypedKV[T]) Get(ctx context.Context, key string) (val T, rev uint64, isNotExist bool, err error) {
+	entry, isNotExist, err := kv.client().KVGet(ctx, kv.bucket, key)
+	if err != nil || isNotExist {
+		return
+	}
+	val, err = decodeKV[T](entry.Value())
+	return val, entry.Revision(), false, err
+}
+
+// Put 设置 key 的值
+func (kv *TypedKV[T]) Put(ctx context.Context, key string, val T) (rev uint64, err error) {
+	data, err := encodeKV(val)
+	if err != nil {
+		return
+	}
+	return kv.client().KVPut(ctx, kv.bucket, key, data)
+}
+
+// Create key 不存在时设置值
+func (kv *TypedKV[T]) Create(ctx context.Context, key string, val T) (rev uint64, err error) {
+	data, err := encodeKV(val)
+	if err != nil {
+		return
+	}
+	return kv.client().KVCreate(ctx, kv.bucket, key, data)
+}
+
+// Update 当前版本号为 lastRev 时更新值
+func (kv *TypedKV[T]) Update(ctx context.Context, key string, val T, lastRev uint64) (rev uint64, err error) {
+	data, err := encodeKV(val)
+	if err != nil {
+		return
+	}
+	return kv.client().KVUpdate(ctx, kv.bucket, key, data, lastRev)
+}
+
+// Delete 删除 key
+func (kv *TypedKV[T]) Delete(ctx context.Context, key string, opts ...jetstream.KVDeleteOpt) error {
+	return kv.client().KVDelete(ctx, kv.bucket, key, opts...)
+}
+
+// Watch 监听 key 的变化直到 ctx 取消，解码失败的值记录日志后跳过
+func (kv *TypedKV[T]) Watch(ctx context.Context, keys string, handler func(ctx context.Context, entry KVEntry[T]), opts ...jetstream.WatchOpt) error {
+	return kv.client().KVWatch(ctx, kv.bucket, keys, func(ctx context.Context, entry jetstream.KeyValueEntry) {
+		e, err := toKVEntry[T](entry)
+		if err != nil {
+			zlog.Error().Ctx(ctx).Err(err).Str("bucket", kv.bucket).Str("key", entry.Key()).Msg("KV值解码失败")
+			return
+		}
+		handler(ctx, e)
+	}, opts...)
+}
+
+// History 获取 key 的历史值
+func (kv *TypedKV[T]) History(ctx context.Context, key string) (entries []KVEntry[T], err error) {
+	raw, err := kv.client().KVHistory(ctx, kv.bucket, key)
+	if err != nil {
+		return
+	}
+	for _, entry := range raw {
+		e, err := toKVEntry[T](entry)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+	return
+}
+
+func toKVEntry[T any](entry jetstream.KeyValueEntry) (e KVEntry[T], err error) {
+	e = KVEntry[T]{
+		Key:       entry.Key(),
+		Revision:  entry.Revision(),
+		Operation: entry.Operation(),
+		Created:   entry.Created(),
+	}
+	if entry.Operation() == jetstream.KeyValuePut {
+		e.Value, err = decodeKV[T](entry.Value())
+	}
+	return
+}
+
+func encodeKV[T any](val T) ([]byte, error) {
+	switch v := any(val).(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return json.Marshal(val)
+}
+
+func decodeKV[T any](data []byte) (val T, err error) {
+	switch p := any(&val).(type) {
+	case *[]byte:
+		*p = data
+	case *string:
+		*p = string(data)
+	default:
+		err = json.Unmarshal(data, &val)
+	}
+	return
+}
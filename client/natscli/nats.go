@@ -1,98 +1,252 @@
 package natscli
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/chenparty/gog/zlog"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
-var nc *nats.Conn
+// healthName 注册到 health 的依赖名称
+const healthName = "nats"
 
 type Options struct {
 	// 连接基础配置项
-	reconnectWait time.Duration // 每次重连等待时间
-	maxReconnects int           // 最大重连次数
+	ReconnectWait time.Duration `yaml:"reconnect_wait" env:"RECONNECT_WAIT"` // 每次重连等待时间
+	MaxReconnects int           `yaml:"max_reconnects" env:"MAX_RECONNECTS"` // 最大重连次数
 
 	// 认证配置-用户名密码方式
-	Username string // 用户名
-	Password string // 密码
+	Username string `yaml:"username" env:"USERNAME"`               // 用户名
+	Password string `yaml:"password" env:"PASSWORD" secret:"true"` // 密码
 	// 认证配置-NKey方式
-	NKeySeedFile string
+	NKeySeedFile string `yaml:"nkey_seed_file" env:"NKEY_SEED_FILE"`
 	// 认证配置-TOKEN方式
-	Token string
+	Token string `yaml:"token" env:"TOKEN" secret:"true"`
 
 	//启用JetStream
-	EnableJetStream bool
+	EnableJetStream bool `yaml:"enable_jetstream" env:"ENABLE_JETSTREAM"`
+
+	// LazyConnect 延迟连接，服务端不可用时不返回错误，服务以降级状态启动，在后台按重连配置持续尝试连接
+	LazyConnect bool `yaml:"lazy_connect" env:"LAZY_CONNECT"`
+
+	// DrainTimeout 关闭连接时等待订阅处理完已收到消息的最长时间，超时后直接关闭
+	DrainTimeout time.Duration `yaml:"drain_timeout" env:"DRAIN_TIMEOUT"`
 }
 
 type Option func(*Options)
 
-// Connect NATS连接
+// DefaultOptions 默认配置
+func DefaultOptions() Options {
+	return Options{
+		ReconnectWait: time.Second * 30,
+		MaxReconnects: 120,
+		DrainTimeout:  time.Second * 30,
+	}
+}
+
+// WithOptions 使用完整的配置替换当前配置，一般用于从配置文件加载，应基于 DefaultOptions 修改
+func WithOptions(o Options) Option {
+	return func(options *Options) {
+		*options = o
+	}
+}
+
+// Client NATS 客户端实例
+type Client struct {
+	nc  *nats.Conn
+	jsc nats.JetStreamContext
+	js  jetstream.JetStream
+
+	kvs  sync.Map // KV 桶名称 -> jetstream.KeyValue
+	objs sync.Map // 对象存储桶名称 -> jetstream.ObjectStore
+
+	drainTimeout time.Duration
+	closed       chan struct{} // 连接关闭后被关闭
+
+	subsMu sync.Mutex
+	subs   map[uint64]*Subscription
+	subSeq uint64
+}
+
+// Connect NATS连接并设置为默认实例，连接失败时 panic
 func Connect(clientName string, servers []string, options ...Option) {
-	opts := Options{
-		reconnectWait: time.Second * 30,
-		maxReconnects: 120,
+	if err := ConnectE(context.Background(), clientName, servers, options...); err != nil {
+		panic(err)
+	}
+}
+
+// ConnectE NATS连接并设置为默认实例，ctx 用于控制连接超时
+func ConnectE(ctx context.Context, clientName string, servers []string, options ...Option) error {
+	c, err := NewContext(ctx, clientName, servers, options...)
+	if err != nil {
+		return err
 	}
+	SetDefault(c)
+	return nil
+}
+
+// New 创建客户端实例，连接多个 NATS 集群时通过 Register 注册为命名实例
+func New(clientName string, servers []string, options ...Option) (c *Client, err error) {
+	return NewContext(context.Background(), clientName, servers, options...)
+}
+
+// NewContext 创建客户端实例，ctx 用于控制连接超时
+func NewContext(ctx context.Context, clientName string, servers []string, options ...Option) (c *Client, err error) {
+	opts := DefaultOptions()
 	for _, opt := range options {
 		if opt != nil {
 			opt(&opts)
 		}
 	}
-	var err error
+	serversStr := strings.Join(servers, ",")
+	if err = validate(servers, opts); err != nil {
+		zlog.Error().Err(err).Str("servers", serversStr).Msg("nats配置错误")
+		return
+	}
 	// 基础配置项
 	natsOpts := []nats.Option{nats.Name(clientName)}
-	natsOpts = append(natsOpts, nats.ReconnectWait(opts.reconnectWait))
-	natsOpts = append(natsOpts, nats.MaxReconnects(opts.maxReconnects))
+	natsOpts = append(natsOpts, nats.ReconnectWait(opts.ReconnectWait))
+	natsOpts = append(natsOpts, nats.MaxReconnects(opts.MaxReconnects))
 	natsOpts = append(natsOpts, nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
 		zlog.Error().Err(err).Msg("nats.DisconnectErrHandler")
 	}))
 	natsOpts = append(natsOpts, nats.ReconnectHandler(func(nc *nats.Conn) {
 		zlog.Info().Str("url", nc.ConnectedUrl()).Msg("NATS reconnected")
 	}))
+	// 连接建立前创建实例，供关闭和异步错误回调使用
+	cli := &Client{drainTimeout: opts.DrainTimeout, closed: make(chan struct{})}
 	natsOpts = append(natsOpts, nats.ClosedHandler(func(nc *nats.Conn) {
 		zlog.Info().Str("url", nc.ConnectedUrl()).Msg("NATS closed")
+		close(cli.closed)
 	}))
+	natsOpts = append(natsOpts, nats.ErrorHandler(cli.asyncErrorHandler))
+	natsOpts = append(natsOpts, nats.DrainTimeout(opts.DrainTimeout))
+	if opts.LazyConnect {
+		// 首次连接失败时在后台重试，连接成功后调用 ConnectHandler
+		natsOpts = append(natsOpts, nats.RetryOnFailedConnect(true))
+		natsOpts = append(natsOpts, nats.ConnectHandler(func(nc *nats.Conn) {
+			zlog.Info().Str("url", nc.ConnectedUrl()).Msg("nats 后台连接成功")
+		}))
+	}
 	// 加密配置
 	if opts.Username != "" && opts.Password != "" {
 		natsOpts = append(natsOpts, nats.UserInfo(opts.Username, opts.Password))
 	} else if opts.NKeySeedFile != "" {
 		var natsOpt nats.Option
-		var e error
-		natsOpt, e = nats.NkeyOptionFromSeed(opts.NKeySeedFile)
-		if e != nil {
-			zlog.Error().Err(e).Str("seedFile", opts.NKeySeedFile).Msg("NkeyOptionFromSeed")
-			panic(e) // NKey 认证失败应该终止启动
+		natsOpt, err = nats.NkeyOptionFromSeed(opts.NKeySeedFile)
+		if err != nil {
+			zlog.Error().Err(err).Str("seedFile", opts.NKeySeedFile).Msg("NkeyOptionFromSeed")
+			return
 		}
 		natsOpts = append(natsOpts, natsOpt)
 	} else if opts.Token != "" {
 		natsOpts = append(natsOpts, nats.Token(opts.Token))
 	}
 	// 发起连接
-	serversStr := strings.Join(servers, ",")
-	nc, err = nats.Connect(serversStr, natsOpts...)
+	nc, err := connect(ctx, serversStr, natsOpts)
 	if err != nil {
 		zlog.Error().Err(err).Str("servers", serversStr).Msg("nats连接失败")
-		panic(err)
+		return
+	}
+	cli.nc = nc
+	c = cli
+	if nc.IsConnected() {
+		zlog.Info().Str("servers", serversStr).Msg("nats连接成功")
+	} else {
+		zlog.Warn().Str("servers", serversStr).Msg("nats 暂不可用，后台重试连接")
 	}
-	zlog.Info().Str("servers", serversStr).Msg("nats连接成功")
 	// Stream配置
 	if opts.EnableJetStream {
-		err = newJetStreamContext()
+		c.jsc, c.js, err = newJetStreamContext(nc)
 		if err != nil {
 			zlog.Error().Err(err).Msg("createJetStreamContext")
-			panic(err)
+			nc.Close()
+			return nil, err
 		}
 		zlog.Info().Str("servers", serversStr).Msg("JetStream Context创建成功")
 	}
+	return
+}
+
+// connect nats.Connect 不支持 ctx，ctx 先结束时直接返回，随后建立的连接会被关闭
+func connect(ctx context.Context, url string, natsOpts []nats.Option) (*nats.Conn, error) {
+	type result struct {
+		nc  *nats.Conn
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		nc, err := nats.Connect(url, natsOpts...)
+		done <- result{nc, err}
+	}()
+	select {
+	case r := <-done:
+		return r.nc, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.nc != nil {
+				r.nc.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// validate 检查连接参数，一次返回所有问题
+func validate(servers []string, opts Options) error {
+	var errs []error
+	if len(servers) == 0 {
+		errs = append(errs, errors.New("natscli: servers is required"))
+	}
+	for i, server := range servers {
+		if server == "" {
+			errs = append(errs, fmt.Errorf("natscli: servers[%d] is empty", i))
+		}
+	}
+	if (opts.Username == "") != (opts.Password == "") {
+		errs = append(errs, errors.New("natscli: username and password must be set together"))
+	}
+	if opts.NKeySeedFile != "" {
+		if _, err := os.Stat(opts.NKeySeedFile); err != nil {
+			errs = append(errs, fmt.Errorf("natscli: nkey seed file: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithLazyConnect 延迟连接，服务端不可用时服务仍可启动，健康检查显示为不可用，直到连接成功
+func WithLazyConnect(enable bool) Option {
+	return func(options *Options) {
+		options.LazyConnect = enable
+	}
+}
+
+// Conn 获取底层的 NATS 连接
+func (c *Client) Conn() *nats.Conn {
+	return c.nc
+}
+
+// Ping 检查连接状态，并通过 Flush 确认与服务端的往返正常
+func (c *Client) Ping(ctx context.Context) error {
+	if status := c.nc.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("nats status: %s", status)
+	}
+	return c.nc.FlushWithContext(ctx)
 }
 
 // NewZlogLoggerWithNATS 使用NATS作为日志输出
 func NewZlogLoggerWithNATS(level string, subj string) {
-	if nc == nil {
+	c := Default()
+	if c == nil {
 		panic("NATS还未创建连接")
 	}
-	zlog.NewLogLogger("NATS", level, zlog.NATSAttr(nc, subj))
+	zlog.NewLogLogger("NATS", level, zlog.NATSAttr(c.nc, subj))
 }
 
 // WithUserAndPass 用户名密码认证
@@ -124,10 +278,49 @@ func WithJetStream(enable bool) Option {
 	}
 }
 
-// Close 关闭 NATS 连接和 JetStream 上下文
-func Close() {
-	if nc != nil {
-		nc.Close()
-		zlog.Info().Msg("NATS 连接已关闭")
+// WithDrainTimeout 设置关闭连接时等待订阅处理完已收到消息的最长时间
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.DrainTimeout = timeout
+	}
+}
+
+// Close 优雅关闭 NATS 连接，等待订阅处理完已收到的消息，最长等待 DrainTimeout
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.drainTimeout)
+	defer cancel()
+	err := c.Drain(ctx)
+	zlog.Info().Msg("NATS 连接已关闭")
+	return err
+}
+
+// Drain 优雅关闭连接：所有订阅停止接收新消息，等待 handler 处理完已收到的消息，
+// 再发送完缓冲中待发布的消息后关闭连接；ctx 先结束时直接关闭连接并返回 ctx.Err()
+func (c *Client) Drain(ctx context.Context) error {
+	err := c.nc.Drain()
+	if errors.Is(err, nats.ErrConnectionClosed) {
+		return nil
+	}
+	if err != nil {
+		// 重连中无法排空，nats 已直接关闭连接
+		zlog.Warn().Ctx(ctx).Err(err).Msg("NATS 排空失败，直接关闭连接")
+		c.nc.Close()
+		return err
+	}
+	select {
+	case <-c.closed:
+		return nil
+	case <-ctx.Done():
+		zlog.Warn().Ctx(ctx).Err(ctx.Err()).Msg("NATS 排空超时，直接关闭连接")
+		c.nc.Close()
+		return ctx.Err()
+	}
+}
+
+// Drain 优雅关闭并移除默认实例，等待订阅处理完已收到的消息，ctx 先结束时直接关闭
+func Drain(ctx context.Context) error {
+	if c, ok := clients.Unregister(DefaultName); ok {
+		return c.Drain(ctx)
 	}
+	return nil
 }
@@ -0,0 +1,225 @@
+package natscli
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/chenparty/gog/zlog"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// CreateOrUpdateObjectStore 使用默认实例创建或更新对象存储桶
+func CreateOrUpdateObjectStore(ctx context.Context, cfg jetstream.ObjectStoreConfig) (jetstream.ObjectStore, error) {
+	return Default().CreateOrUpdateObjectStore(ctx, cfg)
+}
+
+// DeleteObjectStore 使用默认实例删除对象存储桶
+func DeleteObjectStore(ctx context.Context, bucket string) error {
+	return Default().DeleteObjectStore(ctx, bucket)
+}
+
+// ObjectPut 使用默认实例上传对象
+func ObjectPut(ctx context.Context, bucket string, meta jetstream.ObjectMeta, r io.Reader) (*jetstream.ObjectInfo, error) {
+	return Default().ObjectPut(ctx, bucket, meta, r)
+}
+
+// ObjectPutBytes 使用默认实例上传对象
+func ObjectPutBytes(ctx context.Context, bucket, name string, data []byte) (*jetstream.ObjectInfo, error) {
+	return Default().ObjectPutBytes(ctx, bucket, name, data)
+}
+
+// ObjectGet 使用默认实例下载对象
+func ObjectGet(ctx context.Context, bucket, name string) (obj jetstream.ObjectResult, isNotExist bool, err error) {
+	return Default().ObjectGet(ctx, bucket, name)
+}
+
+// ObjectGetBytes 使用默认实例下载对象
+func ObjectGetBytes(ctx context.Context, bucket, name string) (data []byte, isNotExist bool, err error) {
+	return Default().ObjectGetBytes(ctx, bucket, name)
+}
+
+// ObjectInfo 使用默认实例获取对象信息
+func ObjectInfo(ctx context.Context, bucket, name string) (info *jetstream.ObjectInfo, isNotExist bool, err error) {
+	return Default().ObjectInfo(ctx, bucket, name)
+}
+
+// ObjectList 使用默认实例列出对象
+func ObjectList(ctx context.Context, bucket string) ([]*jetstream.ObjectInfo, error) {
+	return Default().ObjectList(ctx, bucket)
+}
+
+// ObjectDelete 使用默认实例删除对象
+func ObjectDelete(ctx context.Context, bucket, name string) error {
+	return Default().ObjectDelete(ctx, bucket, name)
+}
+
+// ObjectLink 使用默认实例创建对象链接
+func ObjectLink(ctx context.Context, bucket, name, targetBucket, targetName string) (*jetstream.ObjectInfo, error) {
+	return Default().ObjectLink(ctx, bucket, name, targetBucket, targetName)
+}
+
+// CreateOrUpdateObjectStore 创建或更新对象存储桶
+func (c *Client) CreateOrUpdateObjectStore(ctx context.Context, cfg jetstream.ObjectStoreConfig) (store jetstream.ObjectStore, err error) {
+	if c.js == nil {
+		return nil, errJetStreamDisabled
+	}
+	store, err = c.js.CreateOrUpdateObjectStore(ctx, cfg)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", cfg.Bucket).Msg("js.CreateOrUpdateObjectStore")
+		return
+	}
+	c.objs.Store(cfg.Bucket, store)
+	return
+}
+
+// DeleteObjectStore 删除对象存储桶及其中所有的对象
+func (c *Client) DeleteObjectStore(ctx context.Context, bucket string) (err error) {
+	if c.js == nil {
+		return errJetStreamDisabled
+	}
+	c.objs.Delete(bucket)
+	if err = c.js.DeleteObjectStore(ctx, bucket); err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Msg("js.DeleteObjectStore")
+	}
+	return
+}
+
+// ObjectStore 获取对象存储桶，结果会被缓存
+func (c *Client) ObjectStore(ctx context.Context, bucket string) (store jetstream.ObjectStore, err error) {
+	if c.js == nil {
+		return nil, errJetStreamDisabled
+	}
+	if v, ok := c.objs.Load(bucket); ok {
+		return v.(jetstream.ObjectStore), nil
+	}
+	store, err = c.js.ObjectStore(ctx, bucket)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Msg("js.ObjectStore")
+		return
+	}
+	c.objs.Store(bucket, store)
+	return
+}
+
+// ObjectPut 从 r 流式上传对象，meta.Name 为对象名称，同名对象会被覆盖
+func (c *Client) ObjectPut(ctx context.Context, bucket string, meta jetstream.ObjectMeta, r io.Reader) (info *jetstream.ObjectInfo, err error) {
+	store, err := c.ObjectStore(ctx, bucket)
+	if err != nil {
+		return
+	}
+	if info, err = store.Put(ctx, meta, r); err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("name", meta.Name).Msg("os.Put")
+	}
+	return
+}
+
+// ObjectPutBytes 上传对象
+func (c *Client) ObjectPutBytes(ctx context.Context, bucket, name string, data []byte) (info *jetstream.ObjectInfo, err error) {
+	store, err := c.ObjectStore(ctx, bucket)
+	if err != nil {
+		return
+	}
+	if info, err = store.PutBytes(ctx, name, data); err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("name", name).Msg("os.PutBytes")
+	}
+	return
+}
+
+// ObjectGet 流式下载对象，链接会被解析为目标对象；读取完成后需要调用 obj.Close，
+// 读取到末尾时校验摘要，不一致时 Read 返回 jetstream.ErrDigestMismatch
+func (c *Client) ObjectGet(ctx context.Context, bucket, name string) (obj jetstream.ObjectResult, isNotExist bool, err error) {
+	store, err := c.ObjectStore(ctx, bucket)
+	if err != nil {
+		return
+	}
+	obj, err = store.Get(ctx, name)
+	if errors.Is(err, jetstream.ErrObjectNotFound) {
+		return nil, true, nil
+	}
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("name", name).Msg("os.Get")
+	}
+	return
+}
+
+// ObjectGetBytes 下载对象到内存，适用于小对象
+func (c *Client) ObjectGetBytes(ctx context.Context, bucket, name string) (data []byte, isNotExist bool, err error) {
+	store, err := c.ObjectStore(ctx, bucket)
+	if err != nil {
+		return
+	}
+	data, err = store.GetBytes(ctx, name)
+	if errors.Is(err, jetstream.ErrObjectNotFound) {
+		return nil, true, nil
+	}
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("name", name).Msg("os.GetBytes")
+	}
+	return
+}
+
+// ObjectInfo 获取对象信息，不下载内容
+func (c *Client) ObjectInfo(ctx context.Context, bucket, name string) (info *jetstream.ObjectInfo, isNotExist bool, err error) {
+	store, err := c.ObjectStore(ctx, bucket)
+	if err != nil {
+		return
+	}
+	info, err = store.GetInfo(ctx, name)
+	if errors.Is(err, jetstream.ErrObjectNotFound) {
+		return nil, true, nil
+	}
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("name", name).Msg("os.GetInfo")
+	}
+	return
+}
+
+// ObjectList 列出桶中所有未删除的对象，桶为空时返回空列表
+func (c *Client) ObjectList(ctx context.Context, bucket string) (infos []*jetstream.ObjectInfo, err error) {
+	store, err := c.ObjectStore(ctx, bucket)
+	if err != nil {
+		return
+	}
+	infos, err = store.List(ctx)
+	if errors.Is(err, jetstream.ErrNoObjectsFound) {
+		return nil, nil
+	}
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Msg("os.List")
+	}
+	return
+}
+
+// ObjectDelete 删除对象
+func (c *Client) ObjectDelete(ctx context.Context, bucket, name string) (err error) {
+	store, err := c.ObjectStore(ctx, bucket)
+	if err != nil {
+		return
+	}
+	if err = store.Delete(ctx, name); err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("name", name).Msg("os.Delete")
+	}
+	return
+}
+
+// ObjectLink 在 bucket 中创建名为 name 的链接，指向 targetBucket 中的 targetName，读取链接时返回目标对象的内容
+func (c *Client) ObjectLink(ctx context.Context, bucket, name, targetBucket, targetName string) (info *jetstream.ObjectInfo, err error) {
+	store, err := c.ObjectStore(ctx, bucket)
+	if err != nil {
+		return
+	}
+	target, err := c.ObjectStore(ctx, targetBucket)
+	if err != nil {
+		return
+	}
+	obj, err := target.GetInfo(ctx, targetName)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", targetBucket).Str("name", targetName).Msg("os.GetInfo")
+		return
+	}
+	if info, err = store.AddLink(ctx, name, obj); err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("bucket", bucket).Str("name", name).Msg("os.AddLink")
+	}
+	return
+}
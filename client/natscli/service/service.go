@@ -0,0 +1,311 @@
+// Package service 基于 NATS micro 的请求-响应服务，服务自动支持 $SRV.PING/INFO/STATS 发现和统计，
+// 错误通过 Nats-Service-Error 消息头返回，handler 的 ctx 带有请求方的 trace_id。
+//
+//	svc, err := service.New("orders", "1.0.0")
+//	v1 := svc.Group("orders.v1")
+//	v1.Handle("get", service.Handle(func(ctx context.Context, req GetOrder) (Order, error) { ... }))
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/chenparty/gog/client/natscli"
+	"github.com/chenparty/gog/client/natscli/codec"
+	"github.com/chenparty/gog/client/natscli/typed"
+	"github.com/chenparty/gog/metrics"
+	"github.com/chenparty/gog/zlog"
+	"github.com/chenparty/gog/zlog/ginplugin"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+	"github.com/rs/zerolog"
+)
+
+// 错误码，与 HTTP 状态码含义一致
+const (
+	CodeBadRequest = "400"
+	CodeInternal   = "500"
+)
+
+type Options struct {
+	Client      *natscli.Client   // 默认使用 natscli 的默认实例
+	Description string            // 服务描述，INFO 中返回
+	Metadata    map[string]string // 服务元数据，INFO 中返回
+	QueueGroup  string            // 队列组，默认 "q"，同名服务的多个实例负载均衡
+	Codec       codec.Codec       // 请求没有 Content-Type 时使用的编码，默认 JSON
+	Stack       bool              // handler panic 时是否记录堆栈
+}
+
+type Option func(*Options)
+
+// WithClient 使用指定的客户端
+func WithClient(c *natscli.Client) Option {
+	return func(options *Options) {
+		options.Client = c
+	}
+}
+
+// WithDescription 设置服务描述
+func WithDescription(description string) Option {
+	return func(options *Options) {
+		options.Description = description
+	}
+}
+
+// WithMetadata 设置服务元数据
+func WithMetadata(metadata map[string]string) Option {
+	return func(options *Options) {
+		options.Metadata = metadata
+	}
+}
+
+// WithQueueGroup 设置队列组
+func WithQueueGroup(queueGroup string) Option {
+	return func(options *Options) {
+		options.QueueGroup = queueGroup
+	}
+}
+
+// WithCodec 设置请求没有 Content-Type 时使用的编码
+func WithCodec(c codec.Codec) Option {
+	return func(options *Options) {
+		options.Codec = c
+	}
+}
+
+// WithStack handler panic 时记录堆栈
+func WithStack(stack bool) Option {
+	return func(options *Options) {
+		options.Stack = stack
+	}
+}
+
+// Service NATS micro 服务，Info、Stats、Stop 等方法来自 micro.Service
+type Service struct {
+	micro.Service
+	name string
+	opts Options
+}
+
+// Group 端点分组，组名作为端点 subject 的前缀
+type Group struct {
+	group micro.Group
+	svc   *Service
+}
+
+// HandlerFunc 端点的处理函数，返回错误时回复错误头：*typed.ServiceError 使用其错误码，
+// 解码失败为 400，其它错误为 500；未调用 Respond 且没有错误时回复空消息
+type HandlerFunc func(c *Context) error
+
+// New 创建并启动服务，version 需要符合 SemVer，如 "1.0.0"
+func New(name, version string, options ...Option) (s *Service, err error) {
+	opts := Options{Codec: codec.JSON}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	if opts.Client == nil {
+		opts.Client = natscli.Default()
+	}
+	s = &Service{name: name, opts: opts}
+	s.Service, err = micro.AddService(opts.Client.Conn(), micro.Config{
+		Name:        name,
+		Version:     version,
+		Description: opts.Description,
+		Metadata:    opts.Metadata,
+		QueueGroup:  opts.QueueGroup,
+		ErrorHandler: func(_ micro.Service, e *micro.NATSError) {
+			zlog.Error().Err(e).Str("service", name).Str("subj", e.Subject).Msg("NATS服务异常")
+		},
+		DoneHandler: func(micro.Service) {
+			zlog.Info().Str("service", name).Msg("NATS服务已停止")
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("natscli: add service %s: %w", name, err)
+	}
+	zlog.Info().Str("service", name).Str("version", version).Str("id", s.Info().ID).Msg("NATS服务已启动")
+	return
+}
+
+// Handle 注册端点，subject 默认与端点名相同，可以通过 micro.WithEndpointSubject 修改
+func (s *Service) Handle(name string, handler HandlerFunc, opts ...micro.EndpointOpt) error {
+	return s.AddEndpoint(name, s.wrap(name, handler), opts...)
+}
+
+// Group 创建端点分组
+func (s *Service) Group(name string, opts ...micro.GroupOpt) *Group {
+	return &Group{group: s.AddGroup(name, opts...), svc: s}
+}
+
+// Handle 在分组中注册端点，subject 为 "组名.端点名"
+func (g *Group) Handle(name string, handler HandlerFunc, opts ...micro.EndpointOpt) error {
+	return g.group.AddEndpoint(name, g.svc.wrap(name, handler), opts...)
+}
+
+// Group 创建子分组
+func (g *Group) Group(name string, opts ...micro.GroupOpt) *Group {
+	return &Group{group: g.group.AddGroup(name, opts...), svc: g.svc}
+}
+
+// Handle 将泛型函数包装为 HandlerFunc，请求按 Content-Type 解码为 Req，响应使用与请求相同的编码
+func Handle[Req, Resp any](fn func(ctx context.Context, req Req) (Resp, error)) HandlerFunc {
+	return func(c *Context) error {
+		var req Req
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		resp, err := fn(c, req)
+		if err != nil {
+			return err
+		}
+		return c.Respond(resp)
+	}
+}
+
+// Error 创建带错误码的错误，handler 返回后回复给请求方
+func Error(code, description string) error {
+	return &typed.ServiceError{Code: code, Description: description}
+}
+
+func (s *Service) wrap(endpoint string, handler HandlerFunc) micro.Handler {
+	return micro.HandlerFunc(func(req micro.Request) {
+		// 端点的 subject 可能包含通配符，实际请求的主题无界，使用服务和端点名作为指标标签
+		metrics.IncNATSReceive(s.name + "/" + endpoint)
+		c := &Context{
+			Context: zlog.NewTraceContextWithID(req.Headers().Get(ginplugin.HeaderRequestID)),
+			Request: req,
+			codec:   s.opts.Codec,
+		}
+		if found, ok := codec.Lookup(req.Headers().Get(codec.HeaderContentType)); ok {
+			c.codec = found
+		}
+		start := time.Now()
+		err := s.call(c, handler)
+		code := ""
+		if err != nil {
+			code = c.respondError(err)
+		} else if !c.responded {
+			if e := c.Request.Respond(nil, c.headers()); e != nil {
+				err = e
+			}
+		}
+
+		var event *zerolog.Event
+		switch {
+		case code == "" && err == nil:
+			event = zlog.Info()
+		case code != "" && !strings.HasPrefix(code, "5"):
+			event = zlog.Warn()
+		default:
+			event = zlog.Error()
+		}
+		event.Ctx(c).Err(err).Str("service", s.name).Str("endpoint", endpoint).
+			Str("subj", req.Subject()).Str("code", code).Dur("latency", time.Since(start)).Msg("NATSRequest")
+	})
+}
+
+func (s *Service) call(c *Context, handler HandlerFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if s.opts.Stack {
+				zlog.Error().Ctx(c).Msg(fmt.Sprint("Recovery from panic:", r, " stack:", string(debug.Stack())))
+			} else {
+				zlog.Error().Ctx(c).Msg(fmt.Sprint("Recovery from panic:", r))
+			}
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(c)
+}
+
+// Context 请求上下文，作为 context.Context 使用时带有请求方的 trace_id
+type Context struct {
+	context.Context
+	Request micro.Request
+
+	codec     codec.Codec
+	responded bool
+}
+
+// Subject 请求的 subject
+func (c *Context) Subject() string {
+	return c.Request.Subject()
+}
+
+// Header 请求头
+func (c *Context) Header(key string) string {
+	return c.Request.Headers().Get(key)
+}
+
+// Data 原始的请求数据
+func (c *Context) Data() []byte {
+	return c.Request.Data()
+}
+
+// Bind 按请求的 Content-Type 解码，失败时返回 *typed.DecodeError
+func (c *Context) Bind(v any) error {
+	ct := c.Header(codec.HeaderContentType)
+	if ct != "" {
+		if _, ok := codec.Lookup(ct); !ok {
+			return &typed.DecodeError{ContentType: ct, Err: errors.New("unsupported content type")}
+		}
+	}
+	if err := c.codec.Unmarshal(c.Data(), v); err != nil {
+		return &typed.DecodeError{ContentType: c.codec.ContentType(), Err: err}
+	}
+	return nil
+}
+
+// Respond 使用与请求相同的编码回复
+func (c *Context) Respond(v any) error {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.RespondRaw(data)
+}
+
+// RespondRaw 回复原始数据
+func (c *Context) RespondRaw(data []byte) error {
+	c.responded = true
+	return c.Request.Respond(data, c.headers())
+}
+
+// respondError 回复错误头，返回错误码
+func (c *Context) respondError(err error) (code string) {
+	code, description := CodeInternal, err.Error()
+	var se *typed.ServiceError
+	var de *typed.DecodeError
+	switch {
+	case errors.As(err, &se):
+		description = se.Description
+		if se.Code != "" {
+			code = se.Code
+		}
+	case errors.As(err, &de):
+		code = CodeBadRequest
+	}
+	if c.responded {
+		return
+	}
+	c.responded = true
+	if e := c.Request.Error(code, description, nil, c.headers()); e != nil {
+		zlog.Error().Ctx(c).Err(e).Str("subj", c.Subject()).Msg("req.Error")
+	}
+	return
+}
+
+func (c *Context) headers() micro.RespondOpt {
+	h := micro.Headers{}
+	nats.Header(h).Set(codec.HeaderContentType, c.codec.ContentType())
+	if traceID := zlog.TraceIDFromContext(c); traceID != "" {
+		nats.Header(h).Set(ginplugin.HeaderRequestID, traceID)
+	}
+	return micro.WithHeaders(h)
+}
@@ -0,0 +1,260 @@
+package natscli
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/chenparty/gog/metrics"
+	"github.com/chenparty/gog/zlog"
+	"github.com/nats-io/nats.go"
+)
+
+type SubOptions struct {
+	Queue        string        // 队列组，同组内的订阅者负载均衡
+	JetStream    bool          // 使用 JetStream 推送订阅
+	JsOpts       []nats.SubOpt // JetStream 订阅选项，如 nats.Durable、nats.DeliverNew
+	PendingMsgs  int           // 积压消息数上限，0 使用 nats 默认值（512K），-1 不限制
+	PendingBytes int           // 积压字节数上限，0 使用 nats 默认值（64MB），-1 不限制
+}
+
+type SubOption func(*SubOptions)
+
+// WithQueue 以队列组方式订阅
+func WithQueue(queue string) SubOption {
+	return func(options *SubOptions) {
+		options.Queue = queue
+	}
+}
+
+// WithJsSub 订阅流消息，opts 为 JetStream 订阅选项
+func WithJsSub(opts ...nats.SubOpt) SubOption {
+	return func(options *SubOptions) {
+		options.JetStream = true
+		options.JsOpts = opts
+	}
+}
+
+// WithPendingLimits 设置积压上限，handler 处理不过来时超出的消息被丢弃并触发慢消费者告警
+func WithPendingLimits(msgs, bytes int) SubOption {
+	return func(options *SubOptions) {
+		options.PendingMsgs = msgs
+		options.PendingBytes = bytes
+	}
+}
+
+// Subscription 订阅句柄，用于取消订阅和查看积压情况
+type Subscription struct {
+	ID        uint64
+	Subject   string
+	Queue     string
+	JetStream bool
+	CreatedAt time.Time
+
+	sub *nats.Subscription
+	c   *Client
+}
+
+// SubscriptionInfo 订阅的诊断信息
+type SubscriptionInfo struct {
+	ID                uint64    `json:"id"`
+	Subject           string    `json:"subject"`
+	Queue             string    `json:"queue,omitempty"`
+	JetStream         bool      `json:"jetstream"`
+	CreatedAt         time.Time `json:"created_at"`
+	Pending           int       `json:"pending"`             // 已收到未处理的消息数
+	PendingBytes      int       `json:"pending_bytes"`       // 已收到未处理的字节数
+	PendingLimit      int       `json:"pending_limit"`       // 积压消息数上限
+	PendingBytesLimit int       `json:"pending_bytes_limit"` // 积压字节数上限
+	Delivered         int64     `json:"delivered"`           // 已交给 handler 的消息数
+	Dropped           int       `json:"dropped"`             // 积压超过上限被丢弃的消息数
+}
+
+// Subscribe 使用默认实例订阅，返回的句柄用于取消订阅
+func Subscribe(subj string, handler nats.MsgHandler, options ...SubOption) (*Subscription, error) {
+	return Default().Subscribe(subj, handler, options...)
+}
+
+// Subscriptions 列出默认实例的有效订阅
+func Subscriptions() []SubscriptionInfo {
+	return Default().Subscriptions()
+}
+
+// Subscribe 订阅消息并登记到订阅列表，返回的句柄用于取消订阅；连接关闭时订阅随之失效
+func (c *Client) Subscribe(subj string, handler nats.MsgHandler, options ...SubOption) (s *Subscription, err error) {
+	var opts SubOptions
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	if opts.JetStream && c.jsc == nil {
+		return nil, errJetStreamDisabled
+	}
+	handler = countReceived(subj, handler)
+	var sub *nats.Subscription
+	switch {
+	case opts.JetStream && opts.Queue != "":
+		sub, err = c.jsc.QueueSubscribe(subj, opts.Queue, handler, opts.JsOpts...)
+	case opts.JetStream:
+		sub, err = c.jsc.Subscribe(subj, handler, opts.JsOpts...)
+	case opts.Queue != "":
+		sub, err = c.nc.QueueSubscribe(subj, opts.Queue, handler)
+	default:
+		sub, err = c.nc.Subscribe(subj, handler)
+	}
+	if err != nil {
+		zlog.Error().Err(err).Str("subj", subj).Str("queue", opts.Queue).Msg("nats订阅失败")
+		return
+	}
+	if opts.PendingMsgs != 0 || opts.PendingBytes != 0 {
+		msgs, bytes, _ := sub.PendingLimits()
+		if opts.PendingMsgs != 0 {
+			msgs = opts.PendingMsgs
+		}
+		if opts.PendingBytes != 0 {
+			bytes = opts.PendingBytes
+		}
+		if err = sub.SetPendingLimits(msgs, bytes); err != nil {
+			zlog.Error().Err(err).Str("subj", subj).Msg("sub.SetPendingLimits")
+			_ = sub.Unsubscribe()
+			return
+		}
+	}
+	return c.register(sub, subj, opts.Queue, opts.JetStream), nil
+}
+
+// Subscriptions 列出有效的订阅，按订阅顺序排列，已失效的订阅从列表中移除
+func (c *Client) Subscriptions() []SubscriptionInfo {
+	c.subsMu.Lock()
+	subs := make([]*Subscription, 0, len(c.subs))
+	for id, s := range c.subs {
+		if !s.sub.IsValid() {
+			delete(c.subs, id)
+			continue
+		}
+		subs = append(subs, s)
+	}
+	c.subsMu.Unlock()
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	infos := make([]SubscriptionInfo, 0, len(subs))
+	for _, s := range subs {
+		infos = append(infos, s.Info())
+	}
+	return infos
+}
+
+// register 登记订阅，JetStream 推送订阅的 sub.Subject 为投递主题，使用订阅时的主题
+func (c *Client) register(sub *nats.Subscription, subj, queue string, jetStream bool) *Subscription {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if c.subs == nil {
+		c.subs = make(map[uint64]*Subscription)
+	}
+	c.subSeq++
+	s := &Subscription{
+		ID:        c.subSeq,
+		Subject:   subj,
+		Queue:     queue,
+		JetStream: jetStream,
+		CreatedAt: time.Now(),
+		sub:       sub,
+		c:         c,
+	}
+	c.subs[s.ID] = s
+	return s
+}
+
+func (c *Client) unregister(id uint64) {
+	c.subsMu.Lock()
+	delete(c.subs, id)
+	c.subsMu.Unlock()
+}
+
+// subject 获取订阅时的主题，未登记的订阅（如 Consume 创建的）使用 sub.Subject
+func (c *Client) subject(sub *nats.Subscription) string {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	for _, s := range c.subs {
+		if s.sub == sub {
+			return s.Subject
+		}
+	}
+	return sub.Subject
+}
+
+// Sub 获取底层的 nats.Subscription
+func (s *Subscription) Sub() *nats.Subscription {
+	return s.sub
+}
+
+// Unsubscribe 取消订阅，已收到未处理的消息被丢弃；JetStream 的临时消费者会被删除
+func (s *Subscription) Unsubscribe() (err error) {
+	s.c.unregister(s.ID)
+	if err = s.sub.Unsubscribe(); errors.Is(err, nats.ErrBadSubscription) {
+		return nil
+	}
+	return
+}
+
+// Drain 停止接收新消息，等待 handler 处理完已收到的消息后取消订阅；ctx 先结束时直接取消订阅并返回 ctx.Err()
+func (s *Subscription) Drain(ctx context.Context) (err error) {
+	defer s.c.unregister(s.ID)
+	closed := s.sub.StatusChanged(nats.SubscriptionClosed)
+	if err = s.sub.Drain(); err != nil {
+		if errors.Is(err, nats.ErrBadSubscription) {
+			return nil
+		}
+		return
+	}
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		_ = s.sub.Unsubscribe()
+		return ctx.Err()
+	}
+}
+
+// SetPendingLimits 设置积压上限，-1 不限制
+func (s *Subscription) SetPendingLimits(msgs, bytes int) error {
+	return s.sub.SetPendingLimits(msgs, bytes)
+}
+
+// Info 获取订阅的诊断信息
+func (s *Subscription) Info() SubscriptionInfo {
+	info := SubscriptionInfo{
+		ID:        s.ID,
+		Subject:   s.Subject,
+		Queue:     s.Queue,
+		JetStream: s.JetStream,
+		CreatedAt: s.CreatedAt,
+	}
+	info.Pending, info.PendingBytes, _ = s.sub.Pending()
+	info.PendingLimit, info.PendingBytesLimit, _ = s.sub.PendingLimits()
+	info.Delivered, _ = s.sub.Delivered()
+	info.Dropped, _ = s.sub.Dropped()
+	return info
+}
+
+// asyncErrorHandler 处理连接的异步错误，订阅积压超过上限（慢消费者）时记录告警
+func (c *Client) asyncErrorHandler(_ *nats.Conn, sub *nats.Subscription, err error) {
+	if sub == nil {
+		zlog.Error().Err(err).Msg("nats.ErrorHandler")
+		return
+	}
+	subj := c.subject(sub)
+	if errors.Is(err, nats.ErrSlowConsumer) {
+		metrics.IncNATSSlowConsumer(subj)
+		pending, pendingBytes, _ := sub.Pending()
+		limit, bytesLimit, _ := sub.PendingLimits()
+		dropped, _ := sub.Dropped()
+		zlog.Warn().Err(err).Str("subj", subj).Str("queue", sub.Queue).
+			Int("pending", pending).Int("pendingBytes", pendingBytes).
+			Int("limit", limit).Int("bytesLimit", bytesLimit).Int("dropped", dropped).
+			Msg("NATS慢消费者，积压超过上限的消息被丢弃")
+		return
+	}
+	zlog.Error().Err(err).Str("subj", subj).Str("queue", sub.Queue).Msg("nats.ErrorHandler")
+}
@@ -0,0 +1,277 @@
+// Package typed 基于泛型的 NATS 消息收发，按 Content-Type 选择编解码，并通过 Z-Request-ID 消息头传递 trace_id。
+//
+//	typed.Subscribe("order.created", func(ctx context.Context, o Order) error { ... })
+//	resp, err := typed.Request[GetOrder, Order](ctx, "order.get", GetOrder{ID: 1})
+package typed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chenparty/gog/client/natscli"
+	"github.com/chenparty/gog/client/natscli/codec"
+	"github.com/chenparty/gog/metrics"
+	"github.com/chenparty/gog/zlog"
+	"github.com/chenparty/gog/zlog/ginplugin"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// HeaderServiceError 响应方返回错误时的错误信息，与 NATS micro 服务一致
+	HeaderServiceError = "Nats-Service-Error"
+	// HeaderServiceErrorCode 响应方返回错误时的错误码
+	HeaderServiceErrorCode = "Nats-Service-Error-Code"
+)
+
+// defaultRequestTimeout ctx 未设置 deadline 时请求的超时时间
+const defaultRequestTimeout = 5 * time.Second
+
+type Options struct {
+	Client *natscli.Client // 默认使用 natscli 的默认实例
+	Codec  codec.Codec     // 发送时的编码，默认 JSON；接收时按 Content-Type 选择，消息没有 Content-Type 时使用该编码
+	Queue  string          // 队列组，同一队列组内只有一个订阅者收到消息
+
+	// ErrorHandler 解码失败、handler 返回错误或 panic 时调用，默认记录日志
+	ErrorHandler func(ctx context.Context, msg *nats.Msg, err error)
+}
+
+type Option func(*Options)
+
+// WithClient 使用指定的客户端，如 natscli.Use("events")
+func WithClient(c *natscli.Client) Option {
+	return func(options *Options) {
+		options.Client = c
+	}
+}
+
+// WithCodec 设置发送时的编码，以及接收到没有 Content-Type 的消息时使用的编码
+func WithCodec(c codec.Codec) Option {
+	return func(options *Options) {
+		options.Codec = c
+	}
+}
+
+// WithQueue 使用队列组订阅
+func WithQueue(queue string) Option {
+	return func(options *Options) {
+		options.Queue = queue
+	}
+}
+
+// WithErrorHandler 设置错误回调
+func WithErrorHandler(fn func(ctx context.Context, msg *nats.Msg, err error)) Option {
+	return func(options *Options) {
+		options.ErrorHandler = fn
+	}
+}
+
+func newOptions(options []Option) Options {
+	opts := Options{Codec: codec.JSON}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	if opts.Client == nil {
+		opts.Client = natscli.Default()
+	}
+	if opts.ErrorHandler == nil {
+		opts.ErrorHandler = logError
+	}
+	return opts
+}
+
+// ServiceError 响应方返回的错误
+type ServiceError struct {
+	Code        string
+	Description string
+}
+
+func (e *ServiceError) Error() string {
+	if e.Code == "" {
+		return e.Description
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// DecodeError 消息解码失败
+type DecodeError struct {
+	ContentType string
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode %s: %v", e.ContentType, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// Publish 编码并发布消息，消息头带有 Content-Type 和 ctx 中的 trace_id
+func Publish[T any](ctx context.Context, subj string, v T, options ...Option) (err error) {
+	opts := newOptions(options)
+	msg, err := NewMsg(ctx, subj, v, opts.Codec)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("subj", subj).Msg("消息编码失败")
+		return
+	}
+	err = opts.Client.Conn().PublishMsg(msg)
+	metrics.IncNATSPublish(err)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("subj", subj).Msg("nc.PublishMsg")
+	}
+	return
+}
+
+// Subscribe 订阅消息并按 Content-Type 解码为 T，handler 的 ctx 带有发送方的 trace_id
+func Subscribe[T any](subj string, handler func(ctx context.Context, v T) error, options ...Option) (*nats.Subscription, error) {
+	opts := newOptions(options)
+	return opts.Client.Conn().QueueSubscribe(subj, opts.Queue, func(msg *nats.Msg) {
+		metrics.IncNATSReceive(subj)
+		ctx := Context(msg)
+		v, err := Decode[T](msg, opts.Codec)
+		if err != nil {
+			opts.ErrorHandler(ctx, msg, err)
+			return
+		}
+		if err = safeCall(func() error { return handler(ctx, v) }); err != nil {
+			opts.ErrorHandler(ctx, msg, err)
+		}
+	})
+}
+
+// Request 编码请求并等待响应，响应按 Content-Type 解码为 Resp；响应方返回错误时返回 *ServiceError。
+// 超时时间由 ctx 的 deadline 决定，未设置时为 5 秒
+func Request[Req, Resp any](ctx context.Context, subj string, req Req, options ...Option) (resp Resp, err error) {
+	opts := newOptions(options)
+	msg, err := NewMsg(ctx, subj, req, opts.Codec)
+	if err != nil {
+		return
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultRequestTimeout)
+		defer cancel()
+	}
+	reply, err := opts.Client.Conn().RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("subj", subj).Msg("nc.RequestMsgWithContext")
+		return
+	}
+	if desc := reply.Header.Get(HeaderServiceError); desc != "" {
+		return resp, &ServiceError{Code: reply.Header.Get(HeaderServiceErrorCode), Description: desc}
+	}
+	return Decode[Resp](reply, opts.Codec)
+}
+
+// Respond 处理请求并回复，响应使用与请求相同的编码；handler 返回错误时通过错误头回复，
+// 错误码为 *ServiceError 的 Code，其它错误为 500，解码失败为 400
+func Respond[Req, Resp any](subj string, handler func(ctx context.Context, req Req) (Resp, error), options ...Option) (*nats.Subscription, error) {
+	opts := newOptions(options)
+	return opts.Client.Conn().QueueSubscribe(subj, opts.Queue, func(msg *nats.Msg) {
+		metrics.IncNATSReceive(subj)
+		ctx := Context(msg)
+		c := opts.Codec
+		if found, ok := codec.Lookup(msg.Header.Get(codec.HeaderContentType)); ok {
+			c = found
+		}
+		req, err := Decode[Req](msg, opts.Codec)
+		if err != nil {
+			opts.ErrorHandler(ctx, msg, err)
+			respondError(ctx, msg, "400", err.Error())
+			return
+		}
+		var resp Resp
+		err = safeCall(func() (err error) {
+			resp, err = handler(ctx, req)
+			return
+		})
+		if err != nil {
+			opts.ErrorHandler(ctx, msg, err)
+			code, description := "500", err.Error()
+			var se *ServiceError
+			if errors.As(err, &se) {
+				description = se.Description
+				if se.Code != "" {
+					code = se.Code
+				}
+			}
+			respondError(ctx, msg, code, description)
+			return
+		}
+		reply, err := NewMsg(ctx, msg.Reply, resp, c)
+		if err != nil {
+			opts.ErrorHandler(ctx, msg, err)
+			respondError(ctx, msg, "500", err.Error())
+			return
+		}
+		if err = msg.RespondMsg(reply); err != nil {
+			zlog.Error().Ctx(ctx).Err(err).Str("subj", msg.Subject).Msg("msg.RespondMsg")
+		}
+	})
+}
+
+// NewMsg 编码消息，设置 Content-Type 和 ctx 中的 trace_id
+func NewMsg(ctx context.Context, subj string, v any, c codec.Codec) (*nats.Msg, error) {
+	data, err := c.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	msg := nats.NewMsg(subj)
+	msg.Data = data
+	msg.Header.Set(codec.HeaderContentType, c.ContentType())
+	if traceID := zlog.TraceIDFromContext(ctx); traceID != "" {
+		msg.Header.Set(ginplugin.HeaderRequestID, traceID)
+	}
+	return msg, nil
+}
+
+// Decode 按消息的 Content-Type 解码，消息没有 Content-Type 时使用 defaultCodec，不支持的 Content-Type 返回 *DecodeError
+func Decode[T any](msg *nats.Msg, defaultCodec codec.Codec) (v T, err error) {
+	c := defaultCodec
+	if ct := msg.Header.Get(codec.HeaderContentType); ct != "" {
+		var ok bool
+		if c, ok = codec.Lookup(ct); !ok {
+			return v, &DecodeError{ContentType: ct, Err: errors.New("unsupported content type")}
+		}
+	}
+	if err = c.Unmarshal(msg.Data, &v); err != nil {
+		return v, &DecodeError{ContentType: c.ContentType(), Err: err}
+	}
+	return
+}
+
+// Context 创建带有消息 trace_id 的 ctx，消息没有 trace_id 时生成新的
+func Context(msg *nats.Msg) context.Context {
+	return zlog.NewTraceContextWithID(msg.Header.Get(ginplugin.HeaderRequestID))
+}
+
+func respondError(ctx context.Context, msg *nats.Msg, code, description string) {
+	if msg.Reply == "" {
+		return
+	}
+	reply := nats.NewMsg(msg.Reply)
+	reply.Header.Set(HeaderServiceError, description)
+	reply.Header.Set(HeaderServiceErrorCode, code)
+	if traceID := zlog.TraceIDFromContext(ctx); traceID != "" {
+		reply.Header.Set(ginplugin.HeaderRequestID, traceID)
+	}
+	if e := msg.RespondMsg(reply); e != nil {
+		zlog.Error().Ctx(ctx).Err(e).Str("subj", msg.Subject).Msg("msg.RespondMsg")
+	}
+}
+
+func logError(ctx context.Context, msg *nats.Msg, err error) {
+	zlog.Error().Ctx(ctx).Err(err).Str("subj", msg.Subject).Msg("NATS消息处理失败")
+}
+
+// safeCall 将 panic 转换为错误
+func safeCall(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
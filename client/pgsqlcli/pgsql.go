@@ -9,6 +9,7 @@ import (
 
 	"gorm.io/gorm/logger"
 
+	"github.com/chenparty/gog/client/internal/lazy"
 	"github.com/chenparty/gog/zlog"
 	"github.com/chenparty/gog/zlog/gormplugin"
 	"gorm.io/driver/postgres"
@@ -16,9 +17,8 @@ import (
 	"gorm.io/gorm/schema"
 )
 
-var (
-	db *gorm.DB
-)
+// healthName 注册到 health 的依赖名称
+const healthName = "pgsql"
 
 const (
 	DefaultSlowThreshold = time.Second
@@ -26,46 +26,94 @@ const (
 )
 
 type Options struct {
-	TablePrefix   string // 表前缀
-	SingularTable bool   // 使用单数表名
+	TablePrefix   string `yaml:"table_prefix" env:"TABLE_PREFIX"`     // 表前缀
+	SingularTable bool   `yaml:"singular_table" env:"SINGULAR_TABLE"` // 使用单数表名
 
 	// Logger
-	Silent                    bool          // 是否打印 sql 语句
-	ParameterizedQueries      bool          // 使用参数化查询
-	IgnoreRecordNotFoundError bool          // 忽略记录不存在错误
-	SlowThreshold             time.Duration // 慢查询阈值
+	Silent                    bool          `yaml:"silent" env:"SILENT"`                                               // 是否打印 sql 语句
+	ParameterizedQueries      bool          `yaml:"parameterized_queries" env:"PARAMETERIZED_QUERIES"`                 // 使用参数化查询
+	IgnoreRecordNotFoundError bool          `yaml:"ignore_record_not_found_error" env:"IGNORE_RECORD_NOT_FOUND_ERROR"` // 忽略记录不存在错误
+	SlowThreshold             time.Duration `yaml:"slow_threshold" env:"SLOW_THRESHOLD"`                               // 慢查询阈值
 
 	// Connection
-	TimeZone    string // 时区配置，默认 "UTC"
-	SSLMode     string // SSL 模式，默认 "disable"
-	ConnTimeout int    // 连接超时时间（秒），默认 10
+	TimeZone    string `yaml:"time_zone" env:"TIME_ZONE"`       // 时区配置，默认 "Asia/Shanghai"
+	SSLMode     string `yaml:"ssl_mode" env:"SSL_MODE"`         // SSL 模式，默认 "disable"
+	ConnTimeout int    `yaml:"conn_timeout" env:"CONN_TIMEOUT"` // 连接超时时间（秒），默认 10
+
+	// LazyConnect 延迟连接，数据库不可用时不返回错误，服务以降级状态启动，连接池在数据库恢复后自动建立连接
+	LazyConnect bool `yaml:"lazy_connect" env:"LAZY_CONNECT"`
 }
 
 type Option func(*Options)
 
-// Connect 连接数据库
-func Connect(addr, user, pwd, dbName string, options ...Option) {
-	opts := Options{
+// DefaultOptions 默认配置
+func DefaultOptions() Options {
+	return Options{
 		SingularTable: DefaultSingularTable,
 		SlowThreshold: DefaultSlowThreshold,
 		TimeZone:      "Asia/Shanghai", // 默认使用 Asia/Shanghai 时区
 		SSLMode:       "disable",       // 默认禁用 SSL
 		ConnTimeout:   10,              // 默认 10 秒超时
 	}
+}
+
+// WithOptions 使用完整的配置替换当前配置，一般用于从配置文件加载，应基于 DefaultOptions 修改
+func WithOptions(o Options) Option {
+	return func(options *Options) {
+		*options = o
+	}
+}
+
+// Client PostgreSQL 客户端实例
+type Client struct {
+	db   *gorm.DB
+	stop context.CancelFunc // 停止延迟连接模式下的后台等待
+}
+
+// Connect 连接数据库并设置为默认实例，连接失败时 panic
+func Connect(addr, user, pwd, dbName string, options ...Option) {
+	if err := ConnectE(context.Background(), addr, user, pwd, dbName, options...); err != nil {
+		panic(err)
+	}
+}
+
+// ConnectE 连接数据库并设置为默认实例，ctx 用于控制连接超时
+func ConnectE(ctx context.Context, addr, user, pwd, dbName string, options ...Option) error {
+	c, err := NewContext(ctx, addr, user, pwd, dbName, options...)
+	if err != nil {
+		return err
+	}
+	SetDefault(c)
+	return nil
+}
+
+// New 创建客户端实例，多个数据库时通过 Register 注册为命名实例
+func New(addr, user, pwd, dbName string, options ...Option) (c *Client, err error) {
+	return NewContext(context.Background(), addr, user, pwd, dbName, options...)
+}
+
+// NewContext 创建客户端实例，ctx 用于控制连接超时
+func NewContext(ctx context.Context, addr, user, pwd, dbName string, options ...Option) (c *Client, err error) {
+	opts := DefaultOptions()
 	for _, opt := range options {
 		if opt != nil {
 			opt(&opts)
 		}
 	}
+	if err = validate(addr, user, dbName, opts); err != nil {
+		zlog.Error().Str("addr", addr).Err(err).Msg("pgsql 配置错误")
+		return
+	}
 
 	dsn, err := buildDSN(addr, user, pwd, dbName, opts)
 	if err != nil {
 		zlog.Error().Str("addr", addr).Err(err).Msg("pgsql 连接失败")
-		panic(err)
+		return
 	}
 
-	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
 		DisableForeignKeyConstraintWhenMigrating: true,
+		DisableAutomaticPing:                     true, // 由下方按 ctx 和延迟连接模式决定是否验证连接
 		NamingStrategy: schema.NamingStrategy{
 			TablePrefix:   opts.TablePrefix,
 			SingularTable: opts.SingularTable,
@@ -74,19 +122,63 @@ func Connect(addr, user, pwd, dbName string, options ...Option) {
 	})
 	if err != nil {
 		zlog.Error().Str("addr", addr).Err(err).Msg("pgsql 连接失败")
-		panic(err)
+		return
+	}
+	if err = db.Use(gormplugin.NewMetrics()); err != nil {
+		zlog.Error().Str("addr", addr).Err(err).Msg("pgsql 注册指标插件失败")
+		return
 	}
-	// 验证数据库连接
 	sqlDB, err := db.DB()
 	if err != nil {
 		zlog.Error().Str("addr", addr).Err(err).Msg("pgsql 获取底层连接失败")
-		panic(err)
+		return
 	}
-	if err = sqlDB.Ping(); err != nil {
+	c = &Client{db: db}
+	if opts.LazyConnect {
+		var waitCtx context.Context
+		waitCtx, c.stop = context.WithCancel(context.Background())
+		lazy.Wait(waitCtx, "pgsql", addr, sqlDB.PingContext)
+		return
+	}
+	// 验证数据库连接
+	if err = sqlDB.PingContext(ctx); err != nil {
 		zlog.Error().Str("addr", addr).Err(err).Msg("pgsql 连接测试失败")
-		panic(err)
+		_ = sqlDB.Close()
+		return nil, err
 	}
 	zlog.Info().Str("addr", addr).Msg("pgsql 连接成功")
+	return
+}
+
+// NewFromDB 使用已创建的 gorm.DB 创建客户端实例，如测试中使用 sqlite 替代数据库
+func NewFromDB(db *gorm.DB) *Client {
+	return &Client{db: db}
+}
+
+// validate 检查连接参数，一次返回所有问题
+func validate(addr, user, dbName string, opts Options) error {
+	var errs []error
+	if addr == "" {
+		errs = append(errs, errors.New("pgsqlcli: addr is required"))
+	}
+	if user == "" {
+		errs = append(errs, errors.New("pgsqlcli: user is required"))
+	}
+	if dbName == "" {
+		errs = append(errs, errors.New("pgsqlcli: dbName is required"))
+	}
+	switch opts.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("pgsqlcli: invalid ssl mode %q", opts.SSLMode))
+	}
+	if _, err := time.LoadLocation(opts.TimeZone); err != nil {
+		errs = append(errs, fmt.Errorf("pgsqlcli: invalid time zone %q: %w", opts.TimeZone, err))
+	}
+	if opts.SlowThreshold < 0 {
+		errs = append(errs, fmt.Errorf("pgsqlcli: invalid slow threshold %s", opts.SlowThreshold))
+	}
+	return errors.Join(errs...)
 }
 
 func buildDSN(addr, user, pwd, dbName string, opts Options) (string, error) {
@@ -154,6 +246,13 @@ func WithSlowThreshold(slowThreshold time.Duration) Option {
 	}
 }
 
+// WithLazyConnect 延迟连接，数据库不可用时服务仍可启动，健康检查显示为不可用，直到数据库恢复
+func WithLazyConnect(enable bool) Option {
+	return func(options *Options) {
+		options.LazyConnect = enable
+	}
+}
+
 // WithTimeZone 设置时区（例如："UTC", "Asia/Shanghai", "America/New_York"）
 func WithTimeZone(timeZone string) Option {
 	return func(options *Options) {
@@ -181,22 +280,24 @@ func WithConnTimeout(timeout int) Option {
 	}
 }
 
-// DB 获取数据库连接
+// DB 获取默认实例的数据库连接
 func DB(ctx context.Context) *gorm.DB {
-	if db == nil {
+	c := Default()
+	if c == nil {
 		panic("database not initialized")
 	}
-	return db.WithContext(ctx)
+	return c.DB(ctx)
 }
 
 type TransactionFunc func(tx *gorm.DB) error
 
-// StartTransaction 开启事务
+// StartTransaction 使用默认实例开启事务
 func StartTransaction(ctx context.Context, trans TransactionFunc) error {
-	if db == nil {
+	c := Default()
+	if c == nil {
 		return errors.New("database not initialized")
 	}
-	return db.WithContext(ctx).Transaction(trans)
+	return c.StartTransaction(ctx, trans)
 }
 
 // IsRecordNotFoundErr 判断是否记录不存在错误
@@ -204,13 +305,36 @@ func IsRecordNotFoundErr(err error) bool {
 	return errors.Is(err, gorm.ErrRecordNotFound)
 }
 
+// DB 获取数据库连接
+func (c *Client) DB(ctx context.Context) *gorm.DB {
+	return c.db.WithContext(ctx)
+}
+
+// StartTransaction 开启事务
+func (c *Client) StartTransaction(ctx context.Context, trans TransactionFunc) error {
+	return c.db.WithContext(ctx).Transaction(trans)
+}
+
+// Ping 检查数据库连接
+func (c *Client) Ping(ctx context.Context) error {
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 // Close 关闭数据库连接
-func Close() {
-	if db != nil {
-		sqlDB, err := db.DB()
-		if err == nil {
-			_ = sqlDB.Close()
-			zlog.Info().Msg("PostgreSQL 连接已关闭")
-		}
+func (c *Client) Close() (err error) {
+	if c.stop != nil {
+		c.stop()
+	}
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return
+	}
+	if err = sqlDB.Close(); err == nil {
+		zlog.Info().Msg("PostgreSQL 连接已关闭")
 	}
+	return
 }
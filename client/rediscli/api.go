@@ -12,20 +12,25 @@ type RedisValueTypes interface {
 	int | int64 | string | bool | []byte
 }
 
-// Get 获取 key 的值
+// Get 使用默认实例获取 key 的值
 func Get[T RedisValueTypes](ctx context.Context, key string) (val T, isNotExist bool, err error) {
+	return GetFrom[T](ctx, Default(), key)
+}
+
+// GetFrom 使用指定实例获取 key 的值
+func GetFrom[T RedisValueTypes](ctx context.Context, c *Client, key string) (val T, isNotExist bool, err error) {
 	var result any
 	switch any(val).(type) {
 	case string:
-		result, err = redisClient.Get(ctx, key).Result()
+		result, err = c.rdb.Get(ctx, key).Result()
 	case int:
-		result, err = redisClient.Get(ctx, key).Int()
+		result, err = c.rdb.Get(ctx, key).Int()
 	case int64:
-		result, err = redisClient.Get(ctx, key).Int64()
+		result, err = c.rdb.Get(ctx, key).Int64()
 	case bool:
-		result, err = redisClient.Get(ctx, key).Bool()
+		result, err = c.rdb.Get(ctx, key).Bool()
 	case []byte:
-		result, err = redisClient.Get(ctx, key).Bytes()
+		result, err = c.rdb.Get(ctx, key).Bytes()
 	default:
 		err = errors.New("redis get with unsupported type")
 		return
@@ -49,45 +54,110 @@ func Get[T RedisValueTypes](ctx context.Context, key string) (val T, isNotExist
 
 // SetEx 设置key和值，并设置过期时间
 func SetEx(ctx context.Context, key string, val any, exp time.Duration) (err error) {
-	err = redisClient.Set(ctx, key, val, exp).Err()
-	return
+	return Default().SetEx(ctx, key, val, exp)
+}
+
+// SetNX key 不存在时设置key和值，并设置过期时间，返回是否设置成功
+func SetNX(ctx context.Context, key string, val any, exp time.Duration) (ok bool, err error) {
+	return Default().SetNX(ctx, key, val, exp)
 }
 
 // Del 删除key
 func Del(ctx context.Context, key string) (err error) {
-	err = redisClient.Del(ctx, key).Err()
-	return
+	return Default().Del(ctx, key)
+}
+
+// DelIfEqual key 的值等于 val 时删除，返回是否删除，用于释放自己持有的锁
+func DelIfEqual(ctx context.Context, key string, val any) (deleted bool, err error) {
+	return Default().DelIfEqual(ctx, key, val)
 }
 
 // HashSet 设置Hash
 func HashSet(ctx context.Context, key string, val map[string]any, expiration time.Duration) (err error) {
+	return Default().HashSet(ctx, key, val, expiration)
+}
+
+// HashUpdate 更新Hash字段
+func HashUpdate(ctx context.Context, key string, val ...any) (err error) {
+	return Default().HashUpdate(ctx, key, val...)
+}
+
+// HashDel 删除Hash字段
+func HashDel(ctx context.Context, key string, val string) (err error) {
+	return Default().HashDel(ctx, key, val)
+}
+
+// HashGetString 获取Hash字段的值
+func HashGetString(ctx context.Context, key, field string) (result string, isNotExist bool, err error) {
+	return Default().HashGetString(ctx, key, field)
+}
+
+// HashGetAll 获取Hash的所有字段和值
+func HashGetAll(ctx context.Context, key string) (result map[string]string, isNotExist bool, err error) {
+	return Default().HashGetAll(ctx, key)
+}
+
+// Subscribe 订阅一个key
+func Subscribe(ctx context.Context, key string) (subscribe *redis.PubSub) {
+	return Default().Subscribe(ctx, key)
+}
+
+// SetEx 设置key和值，并设置过期时间
+func (c *Client) SetEx(ctx context.Context, key string, val any, exp time.Duration) (err error) {
+	err = c.rdb.Set(ctx, key, val, exp).Err()
+	return
+}
+
+// SetNX key 不存在时设置key和值，并设置过期时间，返回是否设置成功
+func (c *Client) SetNX(ctx context.Context, key string, val any, exp time.Duration) (ok bool, err error) {
+	ok, err = c.rdb.SetNX(ctx, key, val, exp).Result()
+	return
+}
+
+// Del 删除key
+func (c *Client) Del(ctx context.Context, key string) (err error) {
+	err = c.rdb.Del(ctx, key).Err()
+	return
+}
+
+// delIfEqualScript 比较并删除，保证只删除值相等的 key
+var delIfEqualScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+
+// DelIfEqual key 的值等于 val 时删除，返回是否删除，用于释放自己持有的锁
+func (c *Client) DelIfEqual(ctx context.Context, key string, val any) (deleted bool, err error) {
+	n, err := delIfEqualScript.Run(ctx, c.rdb, []string{key}, val).Int()
+	return n == 1, err
+}
+
+// HashSet 设置Hash
+func (c *Client) HashSet(ctx context.Context, key string, val map[string]any, expiration time.Duration) (err error) {
 	// 使用 HSet 命令设置 Hash 值
-	err = redisClient.HMSet(ctx, key, val).Err()
+	err = c.rdb.HMSet(ctx, key, val).Err()
 	if err != nil {
 		return
 	}
 	// 设置过期时间
 	if expiration > 0 {
-		err = redisClient.Expire(ctx, key, expiration).Err()
+		err = c.rdb.Expire(ctx, key, expiration).Err()
 	}
 	return
 }
 
 // HashUpdate 更新Hash字段
-func HashUpdate(ctx context.Context, key string, val ...any) (err error) {
-	err = redisClient.HSet(ctx, key, val).Err()
+func (c *Client) HashUpdate(ctx context.Context, key string, val ...any) (err error) {
+	err = c.rdb.HSet(ctx, key, val).Err()
 	return
 }
 
 // HashDel 删除Hash字段
-func HashDel(ctx context.Context, key string, val string) (err error) {
-	err = redisClient.HDel(ctx, key, val).Err()
+func (c *Client) HashDel(ctx context.Context, key string, val string) (err error) {
+	err = c.rdb.HDel(ctx, key, val).Err()
 	return
 }
 
 // HashGetString 获取Hash字段的值
-func HashGetString(ctx context.Context, key, field string) (result string, isNotExist bool, err error) {
-	result, err = redisClient.HGet(ctx, key, field).Result()
+func (c *Client) HashGetString(ctx context.Context, key, field string) (result string, isNotExist bool, err error) {
+	result, err = c.rdb.HGet(ctx, key, field).Result()
 	if err != nil && errors.Is(err, redis.Nil) {
 		isNotExist = true
 		err = nil
@@ -96,9 +166,9 @@ func HashGetString(ctx context.Context, key, field string) (result string, isNot
 }
 
 // HashGetAll 获取Hash的所有字段和值
-func HashGetAll(ctx context.Context, key string) (result map[string]string, isNotExist bool, err error) {
+func (c *Client) HashGetAll(ctx context.Context, key string) (result map[string]string, isNotExist bool, err error) {
 	// 使用 HGetAll 命令获取 Hash 的所有字段和值
-	result, err = redisClient.HGetAll(ctx, key).Result()
+	result, err = c.rdb.HGetAll(ctx, key).Result()
 	if err != nil && errors.Is(err, redis.Nil) {
 		isNotExist = true
 		err = nil
@@ -107,8 +177,8 @@ func HashGetAll(ctx context.Context, key string) (result map[string]string, isNo
 }
 
 // Subscribe 订阅一个key
-func Subscribe(ctx context.Context, key string) (subscribe *redis.PubSub) {
-	subscribe = redisClient.Subscribe(ctx, key)
+func (c *Client) Subscribe(ctx context.Context, key string) (subscribe *redis.PubSub) {
+	subscribe = c.rdb.Subscribe(ctx, key)
 	return
 }
 
@@ -0,0 +1,46 @@
+package rediscli
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/chenparty/gog/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// metricsHook 记录 Redis 命令耗时的 go-redis Hook
+type metricsHook struct{}
+
+func (metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		metrics.ObserveRedis(cmd.Name(), time.Since(start), ignoreNil(err))
+		return err
+	}
+}
+
+func (metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		metrics.ObserveRedis("pipeline", time.Since(start), ignoreNil(err))
+		return err
+	}
+}
+
+// ignoreNil key 不存在属于正常结果，不计为失败
+func ignoreNil(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
@@ -2,33 +2,85 @@ package rediscli
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/chenparty/gog/client/internal/lazy"
 	"github.com/chenparty/gog/zlog"
 	"github.com/redis/go-redis/v9"
 	"strings"
 )
 
-var redisClient redis.UniversalClient
+// healthName 注册到 health 的依赖名称
+const healthName = "redis"
 
 type Options struct {
-	Username string
-	Password string
-	DB       int
+	Username string `yaml:"username" env:"USERNAME"`
+	Password string `yaml:"password" env:"PASSWORD" secret:"true"`
+	DB       int    `yaml:"db" env:"DB"`
 
-	MasterName       string
-	SentinelUsername string
-	SentinelPassword string
+	MasterName       string `yaml:"master_name" env:"MASTER_NAME"`
+	SentinelUsername string `yaml:"sentinel_username" env:"SENTINEL_USERNAME"`
+	SentinelPassword string `yaml:"sentinel_password" env:"SENTINEL_PASSWORD" secret:"true"`
+
+	// LazyConnect 延迟连接，Redis 不可用时不返回错误，服务以降级状态启动，连接池在 Redis 恢复后自动建立连接
+	LazyConnect bool `yaml:"lazy_connect" env:"LAZY_CONNECT"`
 }
 
 type Option func(*Options)
 
-// Connect 连接redis
+// DefaultOptions 默认配置
+func DefaultOptions() Options {
+	return Options{}
+}
+
+// WithOptions 使用完整的配置替换当前配置，一般用于从配置文件加载
+func WithOptions(o Options) Option {
+	return func(options *Options) {
+		*options = o
+	}
+}
+
+// Client Redis 客户端实例
+type Client struct {
+	rdb  redis.UniversalClient
+	stop context.CancelFunc // 停止延迟连接模式下的后台等待
+}
+
+// Connect 连接redis并设置为默认实例，连接失败时 panic
 func Connect(addrs []string, options ...Option) {
-	opts := Options{}
+	if err := ConnectE(context.Background(), addrs, options...); err != nil {
+		panic(err)
+	}
+}
+
+// ConnectE 连接redis并设置为默认实例，ctx 用于控制连接超时
+func ConnectE(ctx context.Context, addrs []string, options ...Option) error {
+	c, err := NewContext(ctx, addrs, options...)
+	if err != nil {
+		return err
+	}
+	SetDefault(c)
+	return nil
+}
+
+// New 创建客户端实例，多个 Redis 时通过 Register 注册为命名实例
+func New(addrs []string, options ...Option) (c *Client, err error) {
+	return NewContext(context.Background(), addrs, options...)
+}
+
+// NewContext 创建客户端实例，ctx 用于控制连接超时
+func NewContext(ctx context.Context, addrs []string, options ...Option) (c *Client, err error) {
+	opts := DefaultOptions()
 	for _, opt := range options {
 		if opt != nil {
 			opt(&opts)
 		}
 	}
+	addr := strings.Join(addrs, ",")
+	if err = validate(addrs, opts); err != nil {
+		zlog.Error().Str("addr", addr).Err(err).Msg("redis配置错误")
+		return
+	}
 	uniOpt := &redis.UniversalOptions{
 		Addrs:    addrs,
 		Username: opts.Username, Password: opts.Password, DB: opts.DB,
@@ -37,14 +89,50 @@ func Connect(addrs []string, options ...Option) {
 		SentinelPassword: opts.SentinelPassword,
 		IsClusterMode:    len(addrs) > 1,
 	}
-	redisClient = redis.NewUniversalClient(uniOpt)
+	rdb := redis.NewUniversalClient(uniOpt)
+	rdb.AddHook(metricsHook{})
+	c = &Client{rdb: rdb}
+	if opts.LazyConnect {
+		var waitCtx context.Context
+		waitCtx, c.stop = context.WithCancel(context.Background())
+		lazy.Wait(waitCtx, "redis", addr, c.Ping)
+		return
+	}
 	//检测是否连接成功
-	_, err := redisClient.Ping(context.Background()).Result()
-	if err != nil {
-		zlog.Error().Str("addr", strings.Join(addrs, ",")).Err(err).Msg("redis连接失败")
-		panic(err)
+	if err = c.Ping(ctx); err != nil {
+		zlog.Error().Str("addr", addr).Err(err).Msg("redis连接失败")
+		_ = rdb.Close()
+		return nil, err
+	}
+	zlog.Info().Str("addr", addr).Msg("redis连接成功")
+	return
+}
+
+// validate 检查连接参数，一次返回所有问题
+func validate(addrs []string, opts Options) error {
+	var errs []error
+	if len(addrs) == 0 {
+		errs = append(errs, errors.New("rediscli: addrs is required"))
+	}
+	for i, addr := range addrs {
+		if addr == "" {
+			errs = append(errs, fmt.Errorf("rediscli: addrs[%d] is empty", i))
+		}
+	}
+	if opts.DB < 0 {
+		errs = append(errs, fmt.Errorf("rediscli: invalid db %d", opts.DB))
+	}
+	if opts.DB != 0 && len(addrs) > 1 && opts.MasterName == "" {
+		errs = append(errs, errors.New("rediscli: cluster mode does not support db other than 0"))
+	}
+	return errors.Join(errs...)
+}
+
+// WithLazyConnect 延迟连接，Redis 不可用时服务仍可启动，健康检查显示为不可用，直到 Redis 恢复
+func WithLazyConnect(enable bool) Option {
+	return func(options *Options) {
+		options.LazyConnect = enable
 	}
-	zlog.Info().Str("addr", strings.Join(addrs, ",")).Msg("redis连接成功")
 }
 
 // WithUserAndPass 设置用户名和密码
@@ -71,10 +159,23 @@ func WithSentinel(masterName, user, pwd string) Option {
 	}
 }
 
+// Redis 获取底层的 go-redis 客户端
+func (c *Client) Redis() redis.UniversalClient {
+	return c.rdb
+}
+
+// Ping 检查连接
+func (c *Client) Ping(ctx context.Context) error {
+	return c.rdb.Ping(ctx).Err()
+}
+
 // Close 关闭 Redis 连接
-func Close() {
-	if redisClient != nil {
-		_ = redisClient.Close()
+func (c *Client) Close() (err error) {
+	if c.stop != nil {
+		c.stop()
+	}
+	if err = c.rdb.Close(); err == nil {
 		zlog.Info().Msg("Redis 连接已关闭")
 	}
+	return
 }
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chenparty/gog/client/etcdcli"
+	"github.com/chenparty/gog/client/miniocli"
+	"github.com/chenparty/gog/client/mqttcli"
+	"github.com/chenparty/gog/client/mysqlcli"
+	"github.com/chenparty/gog/client/natscli"
+	"github.com/chenparty/gog/client/pgsqlcli"
+	"github.com/chenparty/gog/client/rediscli"
+	"github.com/chenparty/gog/zlog"
+)
+
+// Config gog 组件的配置，业务配置通过内嵌该结构体（yaml:",inline"）扩展。
+// 客户端只有在地址不为空时才会被 InitFromConfig 初始化
+type Config struct {
+	Log   Log   `yaml:"log" envPrefix:"LOG_"`
+	MySQL MySQL `yaml:"mysql" envPrefix:"MYSQL_"`
+	PgSQL PgSQL `yaml:"pgsql" envPrefix:"PGSQL_"`
+	Redis Redis `yaml:"redis" envPrefix:"REDIS_"`
+	NATS  NATS  `yaml:"nats" envPrefix:"NATS_"`
+	MQTT  MQTT  `yaml:"mqtt" envPrefix:"MQTT_"`
+	Etcd  Etcd  `yaml:"etcd" envPrefix:"ETCD_"`
+	MinIO MinIO `yaml:"minio" envPrefix:"MINIO_"`
+}
+
+// Log 日志配置
+type Log struct {
+	Mode  string `yaml:"mode" env:"MODE"`   // stdout、file、nats，默认 stdout
+	Level string `yaml:"level" env:"LEVEL"` // debug、info、warn、error，默认 debug
+
+	// Mode 为 file 时有效
+	File     string `yaml:"file" env:"FILE"`
+	MaxSize  int    `yaml:"max_size" env:"MAX_SIZE"` // MB
+	MaxAge   int    `yaml:"max_age" env:"MAX_AGE"`   // 天
+	Compress bool   `yaml:"compress" env:"COMPRESS"`
+
+	// Mode 为 nats 时有效，使用 NATS 默认实例
+	NATSSubject string `yaml:"nats_subject" env:"NATS_SUBJECT"`
+}
+
+type MySQL struct {
+	Addr     string `yaml:"addr" env:"ADDR"`
+	User     string `yaml:"user" env:"USER"`
+	Password string `yaml:"password" env:"PASSWORD" secret:"true"`
+	DBName   string `yaml:"db_name" env:"DB_NAME"`
+
+	mysqlcli.Options `yaml:",inline"`
+}
+
+type PgSQL struct {
+	Addr     string `yaml:"addr" env:"ADDR"`
+	User     string `yaml:"user" env:"USER"`
+	Password string `yaml:"password" env:"PASSWORD" secret:"true"`
+	DBName   string `yaml:"db_name" env:"DB_NAME"`
+
+	pgsqlcli.Options `yaml:",inline"`
+}
+
+type Redis struct {
+	Addrs []string `yaml:"addrs" env:"ADDRS" envSeparator:","`
+
+	rediscli.Options `yaml:",inline"`
+}
+
+type NATS struct {
+	ClientName string   `yaml:"client_name" env:"CLIENT_NAME"`
+	Servers    []string `yaml:"servers" env:"SERVERS" envSeparator:","`
+
+	natscli.Options `yaml:",inline"`
+}
+
+type MQTT struct {
+	Addr string `yaml:"addr" env:"ADDR"`
+
+	mqttcli.Options `yaml:",inline"`
+}
+
+type Etcd struct {
+	Servers []string `yaml:"servers" env:"SERVERS" envSeparator:","`
+
+	etcdcli.Options `yaml:",inline"`
+}
+
+type MinIO struct {
+	Addr string `yaml:"addr" env:"ADDR"`
+
+	miniocli.Options `yaml:",inline"`
+}
+
+func (c MySQL) Enabled() bool { return c.Addr != "" }
+func (c PgSQL) Enabled() bool { return c.Addr != "" }
+func (c Redis) Enabled() bool { return len(c.Addrs) > 0 }
+func (c NATS) Enabled() bool  { return len(c.Servers) > 0 }
+func (c MQTT) Enabled() bool  { return c.Addr != "" }
+func (c Etcd) Enabled() bool  { return len(c.Servers) > 0 }
+func (c MinIO) Enabled() bool { return c.Addr != "" }
+
+// Default 默认配置，客户端选项使用各客户端包的 DefaultOptions
+func Default() Config {
+	var c Config
+	c.SetDefaults()
+	return c
+}
+
+// SetDefaults 设置默认值，Load 在加载配置文件和环境变量前调用
+func (c *Config) SetDefaults() {
+	c.Log = Log{Mode: "stdout", Level: "debug"}
+	c.MySQL.Options = mysqlcli.DefaultOptions()
+	c.PgSQL.Options = pgsqlcli.DefaultOptions()
+	c.Redis.Options = rediscli.DefaultOptions()
+	c.NATS.Options = natscli.DefaultOptions()
+	c.MQTT.Options = mqttcli.DefaultOptions()
+	c.Etcd.Options = etcdcli.DefaultOptions()
+	c.MinIO.Options = miniocli.DefaultOptions()
+}
+
+// Validate 检查配置，一次返回所有问题；客户端的连接参数在连接时还会再次检查
+func (c *Config) Validate() error {
+	var errs []error
+	required := func(ok bool, field string) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s is required", field))
+		}
+	}
+
+	switch strings.ToLower(c.Log.Mode) {
+	case "stdout", "file":
+	case "nats":
+		required(c.NATS.Enabled(), "nats.servers (log.mode is nats)")
+		required(c.Log.NATSSubject != "", "log.nats_subject")
+	default:
+		errs = append(errs, fmt.Errorf("log.mode %q is invalid", c.Log.Mode))
+	}
+	var level zlog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+
+	if c.MySQL.Enabled() {
+		required(c.MySQL.User != "", "mysql.user")
+		required(c.MySQL.DBName != "", "mysql.db_name")
+	}
+	if c.PgSQL.Enabled() {
+		required(c.PgSQL.User != "", "pgsql.user")
+		required(c.PgSQL.DBName != "", "pgsql.db_name")
+	}
+	if c.MQTT.Enabled() && (c.MQTT.CertFile == "") != (c.MQTT.KeyFile == "") {
+		errs = append(errs, errors.New("mqtt.cert_file and mqtt.key_file must be set together"))
+	}
+	if c.MinIO.Enabled() && (c.MinIO.AccessKeyID == "") != (c.MinIO.SecretAccessKey == "") {
+		errs = append(errs, errors.New("minio.access_key_id and minio.secret_access_key must be set together"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package etcdsource
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// cacheFile 本地缓存文件的内容，保存 etcd 中的原始 key，启动时按当前的结构体重新解析
+type cacheFile struct {
+	Prefix   string            `json:"prefix"`
+	Revision int64             `json:"revision"`
+	Kvs      map[string]string `json:"kvs"`
+}
+
+// readCache 读取本地缓存，前缀不一致时返回错误，避免加载其它服务的配置
+func readCache(file, prefix string) (kvs map[string]string, err error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("etcdsource: read cache: %w", err)
+	}
+	var c cacheFile
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("etcdsource: parse cache %s: %w", file, err)
+	}
+	if c.Prefix != prefix {
+		return nil, fmt.Errorf("etcdsource: cache %s is for prefix %q", file, c.Prefix)
+	}
+	return c.Kvs, nil
+}
+
+// writeCache 先写入临时文件再重命名，避免进程退出时留下不完整的文件
+func writeCache(file, prefix string, rev int64, kvs map[string]string) error {
+	data, err := json.MarshalIndent(cacheFile{Prefix: prefix, Revision: rev, Kvs: kvs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
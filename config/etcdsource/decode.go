@@ -0,0 +1,109 @@
+package etcdsource
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// defaulter 实现了 SetDefaults 的配置，内嵌 config.Config 时自动实现
+type defaulter interface {
+	SetDefaults()
+}
+
+// validator 实现了 Validate 的配置，内嵌 config.Config 时自动实现
+type validator interface {
+	Validate() error
+}
+
+// defaults 创建 T 的默认值，T 实现了 SetDefaults 时调用。只在创建 Provider 时调用一次，
+// 避免默认值中的随机字段（如 MQTT 的 ClientID）在每次变更时都被识别为变化
+func defaults[T any]() *T {
+	cfg := new(T)
+	if d, ok := any(cfg).(defaulter); ok {
+		d.SetDefaults()
+	}
+	return cfg
+}
+
+// decode 将前缀下的 key 组装为 YAML 文档后解析到 base 的副本
+func decode[T any](base *T, prefix string, kvs map[string]string) (*T, error) {
+	root := map[string]any{}
+	// key 与前缀相同时作为完整的 YAML 文档
+	if doc, ok := kvs[prefix]; ok && strings.TrimSpace(doc) != "" {
+		if err := yaml.Unmarshal([]byte(doc), &root); err != nil {
+			return nil, fmt.Errorf("etcdsource: parse %s: %w", prefix, err)
+		}
+		if root == nil {
+			root = map[string]any{}
+		}
+	}
+
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		if key != prefix {
+			keys = append(keys, key)
+		}
+	}
+	// 按 key 排序，保证父字段先于子字段设置
+	sort.Strings(keys)
+	for _, key := range keys {
+		path := strings.Split(strings.Trim(strings.TrimPrefix(key, prefix), "/"), "/")
+		if err := set(root, path, parseValue(kvs[key])); err != nil {
+			return nil, fmt.Errorf("etcdsource: %s: %w", key, err)
+		}
+	}
+
+	data, err := yaml.Marshal(root)
+	if err != nil {
+		return nil, fmt.Errorf("etcdsource: %w", err)
+	}
+	cfg := new(T)
+	*cfg = *base
+	if err = yaml.NewDecoder(bytes.NewReader(data)).Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("etcdsource: parse %s: %w", prefix, err)
+	}
+	if v, ok := any(cfg).(validator); ok {
+		if err = v.Validate(); err != nil {
+			return nil, fmt.Errorf("etcdsource: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// parseValue 按 YAML 解析 value，如数字、布尔值、列表，解析失败时作为字符串
+func parseValue(s string) any {
+	var v any
+	if strings.TrimSpace(s) == "" || yaml.Unmarshal([]byte(s), &v) != nil || v == nil {
+		return s
+	}
+	return v
+}
+
+// set 按路径设置字段，中间的字段不存在时创建
+func set(m map[string]any, path []string, v any) error {
+	for i, name := range path {
+		if name == "" {
+			return errors.New("empty field name")
+		}
+		if i == len(path)-1 {
+			m[name] = v
+			return nil
+		}
+		next, ok := m[name].(map[string]any)
+		if !ok {
+			if m[name] != nil {
+				return fmt.Errorf("field %s is not a mapping", strings.Join(path[:i+1], "."))
+			}
+			next = map[string]any{}
+			m[name] = next
+		}
+		m = next
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package etcdsource
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Change 配置变更，Fields 为发生变化的字段路径，使用 yaml 字段名，如 "log.level"、"ip_whitelist"
+type Change[T any] struct {
+	Old    T
+	New    T
+	Fields []string
+}
+
+// Changed path 本身或其下的字段是否发生变化，如 Changed("log") 在 log.level 变化时返回 true
+func (c Change[T]) Changed(path string) bool {
+	for _, f := range c.Fields {
+		if f == path || strings.HasPrefix(f, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// diff 比较两个配置，返回发生变化的字段路径；结构体逐字段比较，其它类型（包括切片和 map）整体比较
+func diff[T any](old, new *T) (fields []string) {
+	diffValue(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", &fields)
+	return
+}
+
+func diffValue(a, b reflect.Value, path string, fields *[]string) {
+	if a.Kind() == reflect.Pointer {
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				*fields = append(*fields, path)
+			}
+			return
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+	if a.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*fields = append(*fields, path)
+		}
+		return
+	}
+	t := a.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, inline := yamlName(field)
+		if name == "-" {
+			continue
+		}
+		p := path
+		if !inline {
+			p = join(path, name)
+		}
+		diffValue(a.Field(i), b.Field(i), p, fields)
+	}
+}
+
+// yamlName 字段的 yaml 名称，与 go-yaml 的规则一致：没有标签时使用小写的字段名，内嵌结构体使用 inline 时不增加层级
+func yamlName(field reflect.StructField) (name string, inline bool) {
+	tag := field.Tag.Get("yaml")
+	name, opts, _ := strings.Cut(tag, ",")
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "inline" {
+			return "", true
+		}
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
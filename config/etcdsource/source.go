@@ -0,0 +1,273 @@
+// Package etcdsource 从 etcd 加载配置到结构体，监听变更并通知订阅者，支持热更新日志级别、限流阈值、IP 白名单等。
+//
+// 前缀下的每个 key 对应结构体的一个字段，key 去掉前缀后按 "/" 分隔为 yaml 字段路径，value 按 YAML 解析，如
+// 前缀为 "/config/gateway/" 时 "/config/gateway/log/level" 对应 log.level，"/config/gateway/ip_whitelist"
+// 可以设置为 ["10.0.0.0/8"]。key 与前缀相同时，value 作为完整的 YAML 文档，其余 key 覆盖其中的字段
+package etcdsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chenparty/gog/client/etcdcli"
+	"github.com/chenparty/gog/zlog"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+type Options struct {
+	Client    *etcdcli.Client // etcd 客户端，默认使用 etcdcli 的默认实例
+	CacheFile string          // 本地缓存文件，每次加载成功后写入，etcd 不可用时从该文件启动
+	Timeout   time.Duration   // 首次加载的超时时间，ctx 未设置 deadline 时有效，默认 3 秒
+}
+
+type Option func(*Options)
+
+// WithClient 使用指定的 etcd 客户端，如 etcdcli.Use("config")
+func WithClient(c *etcdcli.Client) Option {
+	return func(options *Options) {
+		options.Client = c
+	}
+}
+
+// WithCacheFile 设置本地缓存文件
+func WithCacheFile(file string) Option {
+	return func(options *Options) {
+		options.CacheFile = file
+	}
+}
+
+// WithTimeout 设置首次加载的超时时间
+func WithTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		if timeout > 0 {
+			options.Timeout = timeout
+		}
+	}
+}
+
+// Provider 配置提供者，Get 获取当前配置，Subscribe 订阅变更，Run 监听 etcd 并在变更时更新配置
+type Provider[T any] struct {
+	prefix   string
+	opts     Options
+	defaults *T
+
+	current atomic.Pointer[T]
+
+	mu   sync.Mutex
+	kvs  map[string]string // etcd 中前缀下的所有 key，包括未能生效的变更
+	rev  int64             // 已加载的版本号，从本地缓存启动时为 0
+	subs []*subscriber[T]
+}
+
+type subscriber[T any] struct {
+	fn func(c Change[T])
+}
+
+// New 从 etcd 加载前缀下的配置，etcd 不可用时从本地缓存文件加载，两者都失败时返回错误。
+// T 实现了 SetDefaults 时在解析前调用，实现了 Validate 时在解析后调用，检查失败的配置不会生效
+func New[T any](ctx context.Context, prefix string, options ...Option) (p *Provider[T], err error) {
+	opts := Options{Timeout: 3 * time.Second}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	if opts.Client == nil {
+		opts.Client = etcdcli.Default()
+	}
+	if opts.Client == nil {
+		return nil, errors.New("etcdsource: etcd client is required")
+	}
+	if prefix == "" {
+		return nil, errors.New("etcdsource: prefix is required")
+	}
+	p = &Provider[T]{prefix: prefix, opts: opts, defaults: defaults[T]()}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	kvs, rev, err := opts.Client.GetPrefix(ctx, prefix)
+	if err != nil {
+		if opts.CacheFile == "" {
+			return nil, fmt.Errorf("etcdsource: load %s: %w", prefix, err)
+		}
+		var cacheErr error
+		if kvs, cacheErr = readCache(opts.CacheFile, prefix); cacheErr != nil {
+			return nil, fmt.Errorf("etcdsource: load %s: %w", prefix, errors.Join(err, cacheErr))
+		}
+		zlog.Warn().Err(err).Str("prefix", prefix).Str("file", opts.CacheFile).Msg("etcd不可用，使用本地缓存的配置启动")
+		rev = 0
+	}
+	cfg, err := decode(p.defaults, prefix, kvs)
+	if err != nil {
+		return nil, err
+	}
+	p.current.Store(cfg)
+	p.kvs, p.rev = kvs, rev
+	if rev > 0 {
+		p.saveCache()
+	}
+	return
+}
+
+// Get 获取当前配置，返回的切片和 map 与内部共享，不能修改
+func (p *Provider[T]) Get() T {
+	return *p.current.Load()
+}
+
+// Subscribe 订阅配置变更，fn 在监听协程中按订阅顺序同步调用，不应长时间阻塞；返回的函数用于取消订阅
+func (p *Provider[T]) Subscribe(fn func(c Change[T])) (cancel func()) {
+	s := &subscriber[T]{fn: fn}
+	p.mu.Lock()
+	p.subs = append(p.subs, s)
+	p.mu.Unlock()
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for i, sub := range p.subs {
+			if sub == s {
+				p.subs = append(p.subs[:i:i], p.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Run 监听 etcd 的变更并阻塞，直到 ctx 取消；监听中断（etcd 不可用、版本被压缩）后重新全量加载并继续监听，
+// 一般作为 app.Job 运行
+func (p *Provider[T]) Run(ctx context.Context) error {
+	backoff := minBackoff
+	for ctx.Err() == nil {
+		p.mu.Lock()
+		rev := p.rev
+		p.mu.Unlock()
+		// 从本地缓存启动或监听中断后，重新全量加载
+		if rev == 0 {
+			getCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			kvs, r, err := p.opts.Client.GetPrefix(getCtx, p.prefix)
+			cancel()
+			if err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				zlog.Warn().Err(err).Str("prefix", p.prefix).Dur("backoff", backoff).Msg("加载etcd配置失败，等待重试")
+				if !sleep(ctx, backoff) {
+					break
+				}
+				backoff = min(backoff*2, maxBackoff)
+				continue
+			}
+			p.update(kvs, r)
+			rev = r
+		}
+		backoff = minBackoff
+
+		err := p.watch(ctx, rev)
+		if ctx.Err() != nil {
+			break
+		}
+		zlog.Warn().Err(err).Str("prefix", p.prefix).Msg("etcd配置监听中断，重新加载")
+		p.mu.Lock()
+		p.rev = 0
+		p.mu.Unlock()
+		if !sleep(ctx, minBackoff) {
+			break
+		}
+	}
+	return nil
+}
+
+// watch 从 rev+1 开始监听，返回中断的原因
+func (p *Provider[T]) watch(ctx context.Context, rev int64) error {
+	for wr := range p.opts.Client.WatchPrefix(ctx, p.prefix, rev+1) {
+		if err := wr.Err(); err != nil {
+			return err
+		}
+		if len(wr.Events) == 0 {
+			continue
+		}
+		p.mu.Lock()
+		kvs := make(map[string]string, len(p.kvs))
+		for k, v := range p.kvs {
+			kvs[k] = v
+		}
+		p.mu.Unlock()
+		for _, ev := range wr.Events {
+			if ev.Type == clientv3.EventTypeDelete {
+				delete(kvs, string(ev.Kv.Key))
+			} else {
+				kvs[string(ev.Kv.Key)] = string(ev.Kv.Value)
+			}
+		}
+		p.update(kvs, wr.Header.Revision)
+	}
+	return errors.New("watch channel closed")
+}
+
+// update 解析新的配置，检查通过后替换当前配置、写入本地缓存并通知订阅者
+func (p *Provider[T]) update(kvs map[string]string, rev int64) {
+	p.mu.Lock()
+	p.kvs, p.rev = kvs, rev
+	cfg, err := decode(p.defaults, p.prefix, kvs)
+	if err != nil {
+		p.mu.Unlock()
+		zlog.Error().Err(err).Str("prefix", p.prefix).Int64("revision", rev).Msg("etcd配置无效，忽略本次变更")
+		return
+	}
+	p.saveCache()
+	old := p.current.Load()
+	fields := diff(old, cfg)
+	if len(fields) == 0 {
+		p.mu.Unlock()
+		return
+	}
+	p.current.Store(cfg)
+	subs := append([]*subscriber[T](nil), p.subs...)
+	p.mu.Unlock()
+
+	zlog.Info().Str("prefix", p.prefix).Int64("revision", rev).Strs("fields", fields).Msg("etcd配置已更新")
+	c := Change[T]{Old: *old, New: *cfg, Fields: fields}
+	for _, s := range subs {
+		notify(s, c)
+	}
+}
+
+// saveCache 写入本地缓存，失败时只记录日志
+func (p *Provider[T]) saveCache() {
+	if p.opts.CacheFile == "" {
+		return
+	}
+	if err := writeCache(p.opts.CacheFile, p.prefix, p.rev, p.kvs); err != nil {
+		zlog.Warn().Err(err).Str("file", p.opts.CacheFile).Msg("写入配置缓存文件失败")
+	}
+}
+
+// notify 调用订阅者，panic 不影响其它订阅者
+func notify[T any](s *subscriber[T], c Change[T]) {
+	defer func() {
+		if r := recover(); r != nil {
+			zlog.Error().Any("panic", r).Strs("fields", c.Fields).Msg("配置变更订阅者发生panic")
+		}
+	}()
+	s.fn(c)
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
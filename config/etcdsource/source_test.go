@@ -0,0 +1,155 @@
+package etcdsource_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chenparty/gog/config/etcdsource"
+	"github.com/chenparty/gog/gogtest"
+)
+
+const prefix = "/config/gogtest/"
+
+type testConfig struct {
+	Log struct {
+		Level string `yaml:"level"`
+	} `yaml:"log"`
+	Limit int `yaml:"limit"`
+}
+
+// run 在后台运行 Provider，返回接收变更的通道
+func run(t *testing.T, p *etcdsource.Provider[testConfig]) <-chan etcdsource.Change[testConfig] {
+	t.Helper()
+	changes := make(chan etcdsource.Change[testConfig], 16)
+	p.Subscribe(func(c etcdsource.Change[testConfig]) { changes <- c })
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = p.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return changes
+}
+
+func wait(t *testing.T, changes <-chan etcdsource.Change[testConfig]) etcdsource.Change[testConfig] {
+	t.Helper()
+	select {
+	case c := <-changes:
+		return c
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for config change")
+		return etcdsource.Change[testConfig]{}
+	}
+}
+
+func TestInitialLoad(t *testing.T) {
+	c, _ := gogtest.Etcd(t)
+	ctx := t.Context()
+	if err := c.Put(ctx, prefix, "log:\n  level: info\nlimit: 10\n", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(ctx, prefix+"limit", "20", 0); err != nil {
+		t.Fatal(err)
+	}
+	p, err := etcdsource.New[testConfig](ctx, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := p.Get()
+	if cfg.Log.Level != "info" || cfg.Limit != 20 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestWatchUpdate(t *testing.T) {
+	c, _ := gogtest.Etcd(t)
+	ctx := t.Context()
+	if err := c.Put(ctx, prefix+"log/level", "info", 0); err != nil {
+		t.Fatal(err)
+	}
+	p, err := etcdsource.New[testConfig](ctx, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := run(t, p)
+
+	if err = c.Put(ctx, prefix+"log/level", "debug", 0); err != nil {
+		t.Fatal(err)
+	}
+	change := wait(t, changes)
+	if !change.Changed("log") || change.Changed("limit") {
+		t.Fatalf("unexpected fields: %v", change.Fields)
+	}
+	if change.Old.Log.Level != "info" || change.New.Log.Level != "debug" || p.Get().Log.Level != "debug" {
+		t.Fatalf("unexpected change: %+v -> %+v", change.Old, change.New)
+	}
+}
+
+func TestCompactionReload(t *testing.T) {
+	c, _ := gogtest.Etcd(t)
+	ctx := t.Context()
+	if err := c.Put(ctx, prefix+"limit", "1", 0); err != nil {
+		t.Fatal(err)
+	}
+	p, err := etcdsource.New[testConfig](ctx, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 监听开始前的版本被压缩，监听返回 ErrCompacted 后重新全量加载
+	for _, v := range []string{"2", "3"} {
+		if err = c.Put(ctx, prefix+"limit", v, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, rev, err := c.GetPrefix(ctx, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Etcd().Compact(ctx, rev); err != nil {
+		t.Fatal(err)
+	}
+	changes := run(t, p)
+
+	change := wait(t, changes)
+	if change.Old.Limit != 1 || change.New.Limit != 3 {
+		t.Fatalf("unexpected change: %d -> %d", change.Old.Limit, change.New.Limit)
+	}
+	// 重新加载后继续监听
+	if err = c.Put(ctx, prefix+"limit", "4", 0); err != nil {
+		t.Fatal(err)
+	}
+	if change = wait(t, changes); change.New.Limit != 4 {
+		t.Fatalf("unexpected limit after reload: %d", change.New.Limit)
+	}
+}
+
+func TestCacheFallback(t *testing.T) {
+	c, e := gogtest.Etcd(t)
+	ctx := t.Context()
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := c.Put(ctx, prefix+"log/level", "warn", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := etcdsource.New[testConfig](ctx, prefix, etcdsource.WithCacheFile(file)); err != nil {
+		t.Fatal(err)
+	}
+
+	e.Close()
+	p, err := etcdsource.New[testConfig](ctx, prefix, etcdsource.WithCacheFile(file), etcdsource.WithTimeout(500*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level := p.Get().Log.Level; level != "warn" {
+		t.Fatalf("unexpected level from cache: %q", level)
+	}
+	// 没有缓存文件时返回错误
+	if _, err = etcdsource.New[testConfig](ctx, prefix, etcdsource.WithTimeout(500*time.Millisecond)); err == nil {
+		t.Fatal("expected error without cache file")
+	}
+}
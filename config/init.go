@@ -0,0 +1,108 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chenparty/gog/client/etcdcli"
+	"github.com/chenparty/gog/client/miniocli"
+	"github.com/chenparty/gog/client/mqttcli"
+	"github.com/chenparty/gog/client/mysqlcli"
+	"github.com/chenparty/gog/client/natscli"
+	"github.com/chenparty/gog/client/pgsqlcli"
+	"github.com/chenparty/gog/client/rediscli"
+	"github.com/chenparty/gog/zlog"
+)
+
+// InitFromConfig 按配置初始化日志和所有已配置的客户端（设置为各客户端包的默认实例），
+// 任一客户端失败时继续初始化其余客户端，最后返回所有错误；ctx 用于控制连接超时。
+// 已连接（默认实例已存在）的客户端会被跳过，失败后重试只连接失败的客户端
+func InitFromConfig(ctx context.Context, cfg Config) error {
+	// 日志输出到 NATS 时需要先建立 NATS 连接
+	logToNATS := strings.EqualFold(cfg.Log.Mode, "nats")
+	if !logToNATS {
+		if err := initLog(cfg.Log); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	collect := func(name string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	if c := cfg.NATS; c.Enabled() && natscli.Default() == nil {
+		collect("nats", natscli.ConnectE(ctx, c.ClientName, c.Servers, natscli.WithOptions(c.Options)))
+	}
+	if logToNATS {
+		if err := initLog(cfg.Log); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+	}
+	if c := cfg.MySQL; c.Enabled() && mysqlcli.Default() == nil {
+		collect("mysql", mysqlcli.ConnectE(ctx, c.Addr, c.User, c.Password, c.DBName, mysqlcli.WithOptions(c.Options)))
+	}
+	if c := cfg.PgSQL; c.Enabled() && pgsqlcli.Default() == nil {
+		collect("pgsql", pgsqlcli.ConnectE(ctx, c.Addr, c.User, c.Password, c.DBName, pgsqlcli.WithOptions(c.Options)))
+	}
+	if c := cfg.Redis; c.Enabled() && rediscli.Default() == nil {
+		collect("redis", rediscli.ConnectE(ctx, c.Addrs, rediscli.WithOptions(c.Options)))
+	}
+	if c := cfg.MQTT; c.Enabled() && mqttcli.Default() == nil {
+		collect("mqtt", mqttcli.ConnectE(ctx, c.Addr, mqttcli.WithOptions(c.Options)))
+	}
+	if c := cfg.Etcd; c.Enabled() && etcdcli.Default() == nil {
+		collect("etcd", etcdcli.ConnectE(ctx, c.Servers, etcdcli.WithOptions(c.Options)))
+	}
+	if c := cfg.MinIO; c.Enabled() && miniocli.Default() == nil {
+		collect("minio", miniocli.ConnectE(ctx, c.Addr, miniocli.WithOptions(c.Options)))
+	}
+	zlog.Info().Any("config", Masked(cfg)).Msg("配置加载完成")
+	return errors.Join(errs...)
+}
+
+// Close 按初始化的逆序关闭所有已配置的客户端
+func Close(cfg Config) {
+	if cfg.MinIO.Enabled() {
+		miniocli.Close()
+	}
+	if cfg.Etcd.Enabled() {
+		etcdcli.Close()
+	}
+	if cfg.MQTT.Enabled() {
+		mqttcli.Close()
+	}
+	if cfg.Redis.Enabled() {
+		rediscli.Close()
+	}
+	if cfg.PgSQL.Enabled() {
+		pgsqlcli.Close()
+	}
+	if cfg.MySQL.Enabled() {
+		mysqlcli.Close()
+	}
+	if cfg.NATS.Enabled() {
+		natscli.Close()
+	}
+}
+
+func initLog(c Log) error {
+	var options []zlog.Option
+	switch strings.ToLower(c.Mode) {
+	case "file":
+		options = append(options, zlog.FileAttr(c.File, c.MaxSize, c.MaxAge, c.Compress))
+	case "nats":
+		nc := natscli.Default()
+		if nc == nil {
+			return errors.New("log: nats client is not connected")
+		}
+		options = append(options, zlog.NATSAttr(nc.Conn(), c.NATSSubject))
+	}
+	if err := zlog.NewLogLoggerE(c.Mode, c.Level, options...); err != nil {
+		return fmt.Errorf("log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/caarlos0/env/v11"
+	"github.com/goccy/go-yaml"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+)
+
+type Options struct {
+	Files     []string // 配置文件，按扩展名识别 YAML（.yaml、.yml）和 TOML（.toml），后面的覆盖前面的
+	DotEnv    []string // .env 文件，不存在时忽略，不会覆盖已有的环境变量
+	EnvPrefix string   // 环境变量前缀，如 "APP_" 时读取 APP_MYSQL_ADDR
+}
+
+type Option func(*Options)
+
+// WithFile 从配置文件加载，文件不存在时返回错误
+func WithFile(files ...string) Option {
+	return func(options *Options) {
+		options.Files = append(options.Files, files...)
+	}
+}
+
+// WithDotEnv 从 .env 文件加载环境变量，文件不存在时忽略
+func WithDotEnv(files ...string) Option {
+	return func(options *Options) {
+		options.DotEnv = append(options.DotEnv, files...)
+	}
+}
+
+// WithEnvPrefix 设置环境变量前缀
+func WithEnvPrefix(prefix string) Option {
+	return func(options *Options) {
+		options.EnvPrefix = prefix
+	}
+}
+
+// defaulter 实现了 SetDefaults 的配置，内嵌 Config 时自动实现
+type defaulter interface {
+	SetDefaults()
+}
+
+// validator 实现了 Validate 的配置，内嵌 Config 时自动实现，业务配置可覆盖并调用 Config.Validate
+type validator interface {
+	Validate() error
+}
+
+// Load 加载配置，优先级从低到高：SetDefaults 设置的默认值、配置文件、环境变量（包括 .env 文件），
+// 加载完成后调用 Validate 检查配置。cfg 必须是结构体指针
+func Load[T any](cfg *T, options ...Option) (err error) {
+	var opts Options
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	if d, ok := any(cfg).(defaulter); ok {
+		d.SetDefaults()
+	}
+	for _, file := range opts.Files {
+		if err = loadFile(file, cfg); err != nil {
+			return
+		}
+	}
+	for _, file := range opts.DotEnv {
+		if err = godotenv.Load(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("config: load %s: %w", file, err)
+		}
+	}
+	if err = env.ParseWithOptions(cfg, env.Options{Prefix: opts.EnvPrefix}); err != nil {
+		return fmt.Errorf("config: parse env: %w", err)
+	}
+	if v, ok := any(cfg).(validator); ok {
+		if err = v.Validate(); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadFile 解析配置文件，TOML 先转换为 YAML，两种格式统一使用 yaml 标签
+func loadFile(file string, cfg any) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+	case ".toml":
+		var m map[string]any
+		if err = toml.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("config: parse %s: %w", file, err)
+		}
+		if data, err = yaml.Marshal(m); err != nil {
+			return fmt.Errorf("config: convert %s: %w", file, err)
+		}
+	default:
+		return fmt.Errorf("config: unsupported file type %s", file)
+	}
+	// 空文件返回 io.EOF，视为没有配置
+	if err = yaml.NewDecoder(bytes.NewReader(data)).Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: parse %s: %w", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import "reflect"
+
+// maskedValue 替换敏感字段的值
+const maskedValue = "******"
+
+// Masked 返回配置的副本，带有 secret:"true" 标签且不为空的字符串字段被替换为 ******，用于打印配置
+func Masked[T any](cfg T) T {
+	v := reflect.ValueOf(&cfg).Elem()
+	mask(v)
+	return cfg
+}
+
+func mask(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return
+		}
+		// 复制指针指向的结构体，避免修改原配置
+		cp := reflect.New(v.Elem().Type())
+		cp.Elem().Set(v.Elem())
+		mask(cp.Elem())
+		v.Set(cp)
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			field := t.Field(i)
+			fv := v.Field(i)
+			if !field.IsExported() || !fv.CanSet() {
+				continue
+			}
+			if field.Tag.Get("secret") == "true" && fv.Kind() == reflect.String {
+				if fv.Len() > 0 {
+					fv.SetString(maskedValue)
+				}
+				continue
+			}
+			mask(fv)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+// Package dispatch 订阅消息的并发处理：消息提交到有界的 worker 池中处理，订阅回调立即返回，
+// 慢的 handler 不再阻塞同一订阅（NATS）或同一连接（MQTT）的后续消息。
+//
+//	d := dispatch.New("device-event", dispatch.WithWorkers(32), dispatch.WithMQTTKey(dispatch.MQTTTopic))
+//	mqttcli.Subscribe("device/+/event", 1, d.MQTT(handler)) // 同一设备主题的消息按顺序处理
+//	natscli.Sub("user.info", d.NATS(natsHandler))
+//	defer d.Close(ctx)
+//
+// 订阅回调在消息提交后立即返回，消息随之被确认：paho 在回调返回后发送 MQTT QoS 1 的 PUBACK，
+// JetStream 推送订阅默认在回调返回后 Ack。因此消息在处理前已经确认，处理失败、超时或进程退出时不会重新投递；
+// 需要失败重投的消息不要使用 dispatch，JetStream 消息使用 natscli.Consume 并在 handler 中确认
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/chenparty/gog/client/mqttcli"
+	"github.com/chenparty/gog/zlog"
+	"github.com/chenparty/gog/zlog/ginplugin"
+	"github.com/nats-io/nats.go"
+)
+
+// NATSHandler 处理 NATS 消息，ctx 带有 trace_id 和处理超时
+type NATSHandler func(ctx context.Context, msg *nats.Msg) error
+
+// MQTTHandler 处理 MQTT 消息，ctx 带有 trace_id 和处理超时
+type MQTTHandler func(ctx context.Context, id uint16, topic string, payload []byte) error
+
+var (
+	// ErrClosed 分发器已关闭
+	ErrClosed = errors.New("dispatch: dispatcher is closed")
+	// ErrQueueFull 队列已满，WithDropWhenFull 时返回
+	ErrQueueFull = errors.New("dispatch: queue is full")
+)
+
+type Options struct {
+	Workers      int           // worker 数量，即最大并发数，默认 16
+	QueueSize    int           // 每个队列的长度，默认 256；队列满时提交阻塞，订阅回调随之阻塞，形成背压
+	Timeout      time.Duration // 每条消息的处理超时，默认 30 秒，小于等于 0 时不限制；见 WithTimeout
+	DropWhenFull bool          // 队列满时丢弃消息并返回 ErrQueueFull，不阻塞订阅回调
+
+	NATSKey func(msg *nats.Msg) string                // 默认不保证顺序
+	MQTTKey func(topic string, payload []byte) string // 默认不保证顺序
+}
+
+type Option func(*Options)
+
+// WithWorkers 设置 worker 数量
+func WithWorkers(n int) Option {
+	return func(options *Options) {
+		options.Workers = n
+	}
+}
+
+// WithQueueSize 设置每个队列的长度
+func WithQueueSize(n int) Option {
+	return func(options *Options) {
+		options.QueueSize = n
+	}
+}
+
+// WithTimeout 设置每条消息的处理超时，小于等于 0 时不限制。超时只取消传给 handler 的 ctx，不会中断 handler：
+// 不响应 ctx 取消的 handler 会一直占用 worker，按 key 分配给该 worker 的消息和共享队列中的消息都随之等待
+func WithTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.Timeout = timeout
+	}
+}
+
+// WithDropWhenFull 队列满时丢弃消息，而不是阻塞订阅回调
+func WithDropWhenFull(enable bool) Option {
+	return func(options *Options) {
+		options.DropWhenFull = enable
+	}
+}
+
+// WithNATSKey 设置 NATS 消息的顺序 key，key 相同的消息按收到的顺序依次处理，返回空时不保证顺序
+func WithNATSKey(fn func(msg *nats.Msg) string) Option {
+	return func(options *Options) {
+		options.NATSKey = fn
+	}
+}
+
+// WithMQTTKey 设置 MQTT 消息的顺序 key，key 相同的消息按收到的顺序依次处理，返回空时不保证顺序
+func WithMQTTKey(fn func(topic string, payload []byte) string) Option {
+	return func(options *Options) {
+		options.MQTTKey = fn
+	}
+}
+
+type task struct {
+	ctx context.Context
+	key string
+	fn  func(ctx context.Context) error
+}
+
+// Dispatcher 有界的 worker 池。有 key 的消息按 key 的哈希固定分配给一个 worker，保证顺序；
+// 没有 key 的消息进入共享队列，由空闲的 worker 处理
+type Dispatcher struct {
+	name   string
+	opts   Options
+	queues []chan task // 每个 worker 的队列，处理有 key 的消息
+	shared chan task   // 共享队列，处理没有 key 的消息
+
+	mu        sync.RWMutex // 保证 Close 之后不再有新的 Submit 进入
+	closed    bool
+	closing   chan struct{}  // Close 时关闭，阻塞中的 Submit 随之返回 ErrClosed
+	inflight  sync.WaitGroup // 正在执行的 Submit，全部返回后才关闭队列
+	closeOnce sync.Once
+	done      chan struct{} // 队列中的任务都执行完成后关闭
+	wg        sync.WaitGroup
+}
+
+// New 创建分发器并启动 worker，name 用于日志，不再使用时调用 Close
+func New(name string, options ...Option) *Dispatcher {
+	opts := Options{
+		Workers:   16,
+		QueueSize: 256,
+		Timeout:   30 * time.Second,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	opts.Workers = max(opts.Workers, 1)
+	opts.QueueSize = max(opts.QueueSize, 1)
+	d := &Dispatcher{
+		name:    name,
+		opts:    opts,
+		queues:  make([]chan task, opts.Workers),
+		shared:  make(chan task, opts.QueueSize),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	d.wg.Add(opts.Workers)
+	for i := range d.queues {
+		d.queues[i] = make(chan task, opts.QueueSize)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+// Submit 提交任务，key 相同的任务按提交顺序依次执行，key 为空时不保证顺序。队列满时阻塞直到 ctx 结束或分发器关闭，
+// WithDropWhenFull 时返回 ErrQueueFull。ctx 的值（如 trace_id）传给 fn，ctx 的取消不影响已提交的任务
+func (d *Dispatcher) Submit(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	d.mu.RLock()
+	if d.closed {
+		d.mu.RUnlock()
+		return ErrClosed
+	}
+	d.inflight.Add(1)
+	d.mu.RUnlock()
+	defer d.inflight.Done()
+
+	queue := d.shared
+	if key != "" {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		queue = d.queues[h.Sum32()%uint32(len(d.queues))]
+	}
+	t := task{ctx: context.WithoutCancel(ctx), key: key, fn: fn}
+	if d.opts.DropWhenFull {
+		select {
+		case queue <- t:
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	}
+	select {
+	case queue <- t:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-d.closing:
+		return ErrClosed
+	}
+}
+
+// Close 停止接收新任务，阻塞在队列上的 Submit 返回 ErrClosed，等待已提交的任务执行完成，ctx 先结束时返回 ctx.Err()。
+// 应在订阅取消或连接排空（natscli.Drain）之后调用，否则之后收到的消息会因 ErrClosed 被丢弃
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.closeOnce.Do(func() {
+		d.mu.Lock()
+		d.closed = true
+		d.mu.Unlock()
+		close(d.closing)
+		go func() {
+			// 所有 Submit 返回后才能关闭队列，避免向已关闭的 channel 发送
+			d.inflight.Wait()
+			close(d.shared)
+			for _, queue := range d.queues {
+				close(queue)
+			}
+			d.wg.Wait()
+			close(d.done)
+		}()
+	})
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		zlog.Warn().Ctx(ctx).Err(ctx.Err()).Str("dispatcher", d.name).Msg("dispatch关闭超时，未等待剩余任务完成")
+		return ctx.Err()
+	}
+}
+
+// Pending 获取队列中等待执行的任务数
+func (d *Dispatcher) Pending() (n int) {
+	n = len(d.shared)
+	for _, queue := range d.queues {
+		n += len(queue)
+	}
+	return
+}
+
+// NATS 包装 NATS 消息的 handler，用于 natscli.Sub、QueueSub、Subscribe 等订阅；
+// 消息头 Z-Request-ID 作为 trace_id，handler 返回错误或 panic 时记录日志
+func (d *Dispatcher) NATS(handler NATSHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		ctx := zlog.NewTraceContextWithID(msg.Header.Get(ginplugin.HeaderRequestID))
+		var key string
+		if d.opts.NATSKey != nil {
+			key = d.opts.NATSKey(msg)
+		}
+		err := d.Submit(ctx, key, func(ctx context.Context) error {
+			return handler(ctx, msg)
+		})
+		if err != nil {
+			zlog.Warn().Ctx(ctx).Err(err).Str("dispatcher", d.name).Str("subj", msg.Subject).Msg("dispatch丢弃NATS消息")
+		}
+	}
+}
+
+// MQTT 包装 MQTT 消息的 handler，用于 mqttcli.Subscribe，handler 返回错误或 panic 时记录日志
+func (d *Dispatcher) MQTT(handler MQTTHandler) mqttcli.MsgHandler {
+	return func(id uint16, topic string, payload []byte) {
+		ctx := zlog.NewTraceContext()
+		var key string
+		if d.opts.MQTTKey != nil {
+			key = d.opts.MQTTKey(topic, payload)
+		}
+		err := d.Submit(ctx, key, func(ctx context.Context) error {
+			return handler(ctx, id, topic, payload)
+		})
+		if err != nil {
+			zlog.Warn().Ctx(ctx).Err(err).Str("dispatcher", d.name).Str("topic", topic).Msg("dispatch丢弃MQTT消息")
+		}
+	}
+}
+
+// NATSSubject 使用消息的主题作为顺序 key，同一主题的消息按顺序处理
+func NATSSubject(msg *nats.Msg) string {
+	return msg.Subject
+}
+
+// MQTTTopic 使用消息的主题作为顺序 key，如同一设备主题的消息按顺序处理
+func MQTTTopic(topic string, _ []byte) string {
+	return topic
+}
+
+// work 依次执行 worker 队列和共享队列中的任务，两个队列都关闭后退出
+func (d *Dispatcher) work(queue chan task) {
+	defer d.wg.Done()
+	shared := d.shared
+	for queue != nil || shared != nil {
+		select {
+		case t, ok := <-queue:
+			if !ok {
+				queue = nil
+				continue
+			}
+			d.run(t)
+		case t, ok := <-shared:
+			if !ok {
+				shared = nil
+				continue
+			}
+			d.run(t)
+		}
+	}
+}
+
+// run 执行任务，超时后取消 ctx，panic 和错误记录日志
+func (d *Dispatcher) run(t task) {
+	ctx := t.ctx
+	if d.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.opts.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	var stack []byte
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+				stack = debug.Stack()
+			}
+		}()
+		return t.fn(ctx)
+	}()
+	switch {
+	case stack != nil:
+		zlog.Error().Ctx(ctx).Err(err).Str("dispatcher", d.name).Str("key", t.key).Str("stack", string(stack)).Msg("dispatch处理panic")
+	case err != nil:
+		zlog.Error().Ctx(ctx).Err(err).Str("dispatcher", d.name).Str("key", t.key).Dur("cost", time.Since(start)).Msg("dispatch处理失败")
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		// handler 未响应 ctx 的取消，超时后才返回
+		zlog.Warn().Ctx(ctx).Str("dispatcher", d.name).Str("key", t.key).Dur("cost", time.Since(start)).Msg("dispatch处理超时")
+	}
+}
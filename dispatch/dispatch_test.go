@@ -0,0 +1,169 @@
+package dispatch_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chenparty/gog/dispatch"
+	"github.com/chenparty/gog/gogtest"
+)
+
+func closeDispatcher(t *testing.T, d *dispatch.Dispatcher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyOrder(t *testing.T) {
+	d := dispatch.New("test", dispatch.WithWorkers(4), dispatch.WithQueueSize(16))
+	var mu sync.Mutex
+	got := map[string][]int{}
+	for i := range 100 {
+		key := []string{"a", "b", "c"}[i%3]
+		err := d.Submit(t.Context(), key, func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got[key] = append(got[key], i)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	closeDispatcher(t, d)
+
+	for key, seq := range got {
+		for j := 1; j < len(seq); j++ {
+			if seq[j] < seq[j-1] {
+				t.Fatalf("key %s out of order: %v", key, seq)
+			}
+		}
+	}
+	if n := len(got["a"]) + len(got["b"]) + len(got["c"]); n != 100 {
+		t.Fatalf("unexpected task count: %d", n)
+	}
+}
+
+func TestCloseUnblocksSubmit(t *testing.T) {
+	d := dispatch.New("test", dispatch.WithWorkers(1), dispatch.WithQueueSize(1))
+	release := make(chan struct{})
+	block := func(ctx context.Context) error {
+		<-release
+		return nil
+	}
+	// 第一个任务占用 worker，第二个任务占满队列，第三个阻塞在 Submit
+	for range 2 {
+		if err := d.Submit(t.Context(), "k", block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for d.Pending() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	submitted := make(chan error, 1)
+	go func() { submitted <- d.Submit(t.Context(), "k", block) }()
+
+	closed := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		closed <- d.Close(ctx)
+	}()
+	select {
+	case err := <-submitted:
+		if !errors.Is(err, dispatch.ErrClosed) {
+			t.Fatalf("expected ErrClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Submit still blocked after Close")
+	}
+	close(release)
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Submit(t.Context(), "k", block); !errors.Is(err, dispatch.ErrClosed) {
+		t.Fatalf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestDropWhenFull(t *testing.T) {
+	d := dispatch.New("test", dispatch.WithWorkers(1), dispatch.WithQueueSize(1), dispatch.WithDropWhenFull(true))
+	release := make(chan struct{})
+	block := func(ctx context.Context) error {
+		<-release
+		return nil
+	}
+	if err := d.Submit(t.Context(), "k", block); err != nil {
+		t.Fatal(err)
+	}
+	for d.Pending() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := d.Submit(t.Context(), "k", block); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Submit(t.Context(), "k", block); !errors.Is(err, dispatch.ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	close(release)
+	closeDispatcher(t, d)
+}
+
+func TestTimeout(t *testing.T) {
+	logs := gogtest.CaptureLogs(t, "debug")
+	d := dispatch.New("test", dispatch.WithWorkers(1), dispatch.WithTimeout(50*time.Millisecond))
+	result := make(chan error, 1)
+	err := d.Submit(t.Context(), "", func(ctx context.Context) error {
+		<-ctx.Done()
+		result <- ctx.Err()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-result:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ctx not canceled after timeout")
+	}
+	closeDispatcher(t, d)
+	if !logs.Contains("dispatch处理失败") {
+		t.Fatalf("missing failure log: %s", logs)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	logs := gogtest.CaptureLogs(t, "debug")
+	d := dispatch.New("test", dispatch.WithWorkers(1))
+	if err := d.Submit(t.Context(), "k", func(ctx context.Context) error { panic("boom") }); err != nil {
+		t.Fatal(err)
+	}
+	// panic 之后 worker 继续处理后续任务
+	ran := make(chan struct{})
+	if err := d.Submit(t.Context(), "k", func(ctx context.Context) error {
+		close(ran)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker did not survive panic")
+	}
+	closeDispatcher(t, d)
+
+	entries := logs.Find("dispatch处理panic")
+	if len(entries) != 1 || entries[0].Err() != "panic: boom" || entries[0].Str("stack") == "" {
+		t.Fatalf("unexpected panic logs: %v", entries)
+	}
+}
@@ -1,34 +1,43 @@
 package main
 
 import (
-	"github.com/chenparty/gog/client/mqttcli"
-	"github.com/chenparty/gog/client/mysqlcli"
-	"github.com/chenparty/gog/example/config/app"
+	"context"
+	"github.com/chenparty/gog/app"
+	"github.com/chenparty/gog/config"
+	appcfg "github.com/chenparty/gog/example/config/app"
 	"github.com/chenparty/gog/example/internal/app/api"
 	"github.com/chenparty/gog/example/internal/app/mq"
+	"github.com/chenparty/gog/server"
 	"github.com/chenparty/gog/zlog"
-	"github.com/joho/godotenv"
-	"log"
-	"path/filepath"
 )
 
 func init() {
-	envPath := filepath.Join("example/config/app", ".env")
-	if err := godotenv.Load(envPath); err != nil {
-		log.Printf("No .env file found for service1, using system environment variables")
-	}
 	// 初始化配置
-	app.InitEnv()
-	// 初始化日志
-	if app.Get().Release {
-		zlog.NewLogLogger("file", "info", zlog.FileAttr("log/mtbar.log", 2, 7, true))
-	}
+	appcfg.InitEnv()
 }
 
 func main() {
-	cfg := app.Get()
-	mysqlcli.Connect(cfg.Mysql.Addr, cfg.Mysql.User, cfg.Mysql.Pwd, cfg.Mysql.DbName)
-	mqttcli.Connect(cfg.Mqtt.Addr, mqttcli.AuthWithUser(cfg.Mqtt.User, cfg.Mqtt.Pwd))
-	mq.InitSubscription()
-	api.Init(cfg.Release)
+	cfg := appcfg.Get()
+	// 按依赖顺序启动；收到退出信号后先停止HTTP服务，再按启动的逆序关闭客户端
+	err := app.New().
+		Add(app.Component{
+			Name: "clients",
+			// 初始化日志和配置中的所有客户端（MySQL、MQTT 等）
+			Start: func(ctx context.Context) error {
+				return config.InitFromConfig(ctx, cfg.Config)
+			},
+			Stop: func(ctx context.Context) error {
+				config.Close(cfg.Config)
+				return nil
+			},
+		}).
+		Add(app.Hook("mq-subscription", func(ctx context.Context) error {
+			mq.InitSubscription()
+			return nil
+		}, "clients")).
+		Add(app.HTTPServer("http", server.New(cfg.Http.Addr, api.Init(cfg.Release)), "clients", "mq-subscription")).
+		Run(context.Background())
+	if err != nil {
+		zlog.Error().Err(err).Msg("服务退出")
+	}
 }
@@ -2,43 +2,54 @@ package app
 
 import (
 	"fmt"
-	"github.com/caarlos0/env/v11"
+	"github.com/chenparty/gog/config"
+	"os"
+	"path/filepath"
 )
 
 type AppConfig struct {
-	Release bool
+	// gog 组件配置：日志、MySQL、MQTT 等，环境变量如 MYSQL_ADDR、MQTT_ADDR、LOG_MODE
+	config.Config `yaml:",inline"`
+
+	Release bool `yaml:"release" env:"RELEASE"`
 	Http    struct {
-		Addr string `env:"HTTP_ADDR"`
-	}
-	Mysql struct {
-		Addr   string `env:"MYSQL_ADDR"`
-		User   string `env:"MYSQL_USER"`
-		Pwd    string `env:"MYSQL_PWD"`
-		DbName string `env:"MYSQL_DB_NAME"`
-	}
-	Mqtt struct {
-		Addr string `env:"MQTT_ADDR"`
-		User string `env:"MQTT_USER"`
-		Pwd  string `env:"MQTT_PWD"`
-	}
+		Addr string `yaml:"addr" env:"ADDR"`
+	} `yaml:"http" envPrefix:"HTTP_"`
 }
 
 // 用于存储唯一的配置实例
 var cfg AppConfig
 var initialized bool
 
-// InitEnv 初始化环境变量
+// InitEnv 初始化配置，从 .env 文件和环境变量加载
 func InitEnv() {
-	var err error
-	cfg, err = env.ParseAs[AppConfig]()
+	err := config.Load(&cfg, config.WithDotEnv(filepath.Join("example/config/app", ".env")))
 	if err != nil {
-		fmt.Printf("错误：环境变量加载出现错误：%v\n", err)
-		panic("环境变量加载出现错误")
+		fmt.Printf("错误：配置加载出现错误：%v\n", err)
+		panic("配置加载出现错误")
 	}
+	compat(&cfg)
 	initialized = true
 }
 
-// Get 获取环境变量
+// compat 兼容旧版本的配置：环境变量 MYSQL_PWD、MQTT_USER、MQTT_PWD 在新变量名未设置时仍然有效，
+// 发布模式未配置日志时和以前一样输出到 log/mtbar.log
+func compat(c *AppConfig) {
+	if c.MySQL.Password == "" {
+		c.MySQL.Password = os.Getenv("MYSQL_PWD")
+	}
+	if c.MQTT.Username == "" {
+		c.MQTT.Username = os.Getenv("MQTT_USER")
+	}
+	if c.MQTT.Password == "" {
+		c.MQTT.Password = os.Getenv("MQTT_PWD")
+	}
+	if c.Release && c.Log.Mode == "" {
+		c.Log = config.Log{Mode: "file", Level: "info", File: "log/mtbar.log", MaxSize: 2, MaxAge: 7, Compress: true}
+	}
+}
+
+// Get 获取配置
 func Get() *AppConfig {
 	if !initialized {
 		panic("配置尚未初始化，请先调用 InitEnv() 初始化配置")
@@ -1,27 +1,32 @@
 package api
 
 import (
-	"github.com/chenparty/gog/example/config/app"
 	"github.com/chenparty/gog/example/internal/app/api/handler/user"
 	userService "github.com/chenparty/gog/example/internal/app/api/service/user"
 	"github.com/chenparty/gog/zlog/ginplugin"
 	"github.com/gin-gonic/gin"
+	"time"
 )
 
-func Init(release bool) {
+// Init 创建路由，由 main 使用 server 包运行
+func Init(release bool) *gin.Engine {
 	if release {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	g := gin.New()
-	g.MaxMultipartMemory = 10 << 20 // 设置请求最大体积为 10 MB, 防止恶意请求
+	g.MaxMultipartMemory = 10 << 20      // multipart 表单最多使用 10 MB 内存，超出部分写入临时文件
+	g.ContextWithFallback = true         // handler 直接把 c 作为 ctx 传给客户端时，也能拿到请求的 deadline
+	g.Use(ginplugin.BodyLimit(10 << 20)) // 设置请求最大体积为 10 MB, 防止恶意请求
+	g.Use(ginplugin.SecureHeaders(ginplugin.DefaultSecureConfig()))
 	g.Use(ginplugin.GinRequestIDForTrace())
+	g.Use(ginplugin.Timeout(10*time.Second, nil))
 	g.Use(ginplugin.GinLogger(true, 2000))
 	g.Use(ginplugin.Recovery(true))
+	g.Use(ginplugin.GinMetrics())
+	g.GET("/metrics", ginplugin.MetricsHandler())
+	ginplugin.RegisterHealthRoutes(g, 3*time.Second)
 	registryRouter(g)
-	err := g.Run(app.Get().Http.Addr)
-	if err != nil {
-		panic(err)
-	}
+	return g
 }
 
 func registryRouter(r *gin.Engine) {
@@ -1,7 +1,7 @@
 package user
 
 import (
-	"github.com/chenparty/gog/example/internal/app/api/resp"
+	"github.com/chenparty/gog/resp"
 	"github.com/chenparty/gog/zlog"
 	"github.com/gin-gonic/gin"
 )
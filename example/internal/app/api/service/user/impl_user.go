@@ -2,8 +2,8 @@ package user
 
 import (
 	"context"
-	"github.com/chenparty/gog/example/internal/app/api/resp"
 	"github.com/chenparty/gog/example/internal/model"
+	"github.com/chenparty/gog/resp"
 )
 
 func (s *_defaultService) UserInfo(ctx context.Context, uid string) *resp.Output {
@@ -2,8 +2,8 @@ package user
 
 import (
 	"context"
-	"github.com/chenparty/gog/example/internal/app/api/resp"
 	"github.com/chenparty/gog/example/internal/app/dao/user"
+	"github.com/chenparty/gog/resp"
 )
 
 type Service interface {
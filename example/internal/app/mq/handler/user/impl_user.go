@@ -3,7 +3,7 @@ package user
 import (
 	"encoding/json"
 	"github.com/chenparty/gog/client/mqttcli"
-	"github.com/chenparty/gog/example/internal/app/mq/resp"
+	"github.com/chenparty/gog/resp"
 	"github.com/chenparty/gog/zlog"
 )
 
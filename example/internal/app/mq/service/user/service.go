@@ -3,7 +3,7 @@ package user
 import (
 	"context"
 	"github.com/chenparty/gog/example/internal/app/dao/user"
-	"github.com/chenparty/gog/example/internal/app/mq/resp"
+	"github.com/chenparty/gog/resp"
 )
 
 type Service interface {
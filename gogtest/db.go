@@ -0,0 +1,46 @@
+package gogtest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/chenparty/gog/client/mysqlcli"
+	"github.com/chenparty/gog/client/pgsqlcli"
+	"github.com/chenparty/gog/zlog/gormplugin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// MySQL 使用 sqlite 创建 mysqlcli 的替身，数据库文件位于测试的临时目录，每个测试独立；
+// 表名规则与 mysqlcli 的默认配置一致（单数表名），只支持 MySQL 与 sqlite 兼容的 SQL
+func MySQL(tb testing.TB, options ...Option) *mysqlcli.Client {
+	tb.Helper()
+	opts := newOptions(mysqlcli.DefaultName, options)
+	c := mysqlcli.NewFromDB(openSQLite(tb))
+	use(tb, registry[*mysqlcli.Client]{mysqlcli.Register, mysqlcli.Unregister, mysqlcli.Lookup}, opts.Name, c)
+	return c
+}
+
+// PgSQL 使用 sqlite 创建 pgsqlcli 的替身，只支持 PostgreSQL 与 sqlite 兼容的 SQL
+func PgSQL(tb testing.TB, options ...Option) *pgsqlcli.Client {
+	tb.Helper()
+	opts := newOptions(pgsqlcli.DefaultName, options)
+	c := pgsqlcli.NewFromDB(openSQLite(tb))
+	use(tb, registry[*pgsqlcli.Client]{pgsqlcli.Register, pgsqlcli.Unregister, pgsqlcli.Lookup}, opts.Name, c)
+	return c
+}
+
+func openSQLite(tb testing.TB) *gorm.DB {
+	tb.Helper()
+	dsn := filepath.Join(tb.TempDir(), "gogtest.db") + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		DisableForeignKeyConstraintWhenMigrating: true,
+		NamingStrategy:                           schema.NamingStrategy{SingularTable: true},
+		Logger:                                   gormplugin.NewLogger(gormplugin.Config{}),
+	})
+	if err != nil {
+		tb.Fatalf("gogtest: open sqlite: %v", err)
+	}
+	return db
+}
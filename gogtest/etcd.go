@@ -0,0 +1,56 @@
+package gogtest
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/chenparty/gog/client/etcdcli"
+	"go.etcd.io/etcd/server/v3/embed"
+	"go.uber.org/zap"
+)
+
+// Etcd 启动内嵌的单节点 etcd（数据位于测试的临时目录）并创建 etcdcli 的替身，
+// 返回的 Etcd 可通过 Close 模拟 etcd 不可用
+func Etcd(tb testing.TB, options ...Option) (*etcdcli.Client, *embed.Etcd) {
+	tb.Helper()
+	opts := newOptions(etcdcli.DefaultName, options)
+	clientURL := url.URL{Scheme: "http", Host: freeAddr(tb)}
+	peerURL := url.URL{Scheme: "http", Host: freeAddr(tb)}
+
+	cfg := embed.NewConfig()
+	cfg.Name = "gogtest"
+	cfg.Dir = tb.TempDir()
+	cfg.ZapLoggerBuilder = embed.NewZapLoggerBuilder(zap.NewNop())
+	cfg.ListenClientUrls = []url.URL{clientURL}
+	cfg.AdvertiseClientUrls = []url.URL{clientURL}
+	cfg.ListenPeerUrls = []url.URL{peerURL}
+	cfg.AdvertisePeerUrls = []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		tb.Fatalf("gogtest: start etcd: %v", err)
+	}
+	tb.Cleanup(func() {
+		// 测试中已调用 Close 模拟 etcd 不可用时，不能重复关闭
+		select {
+		case <-e.Server.StopNotify():
+		default:
+			e.Close()
+		}
+	})
+	select {
+	case <-e.Server.ReadyNotify():
+	case err = <-e.Err():
+		tb.Fatalf("gogtest: start etcd: %v", err)
+	case <-time.After(30 * time.Second):
+		tb.Fatal("gogtest: etcd not ready")
+	}
+
+	c, err := etcdcli.NewContext(tb.Context(), []string{clientURL.String()})
+	if err != nil {
+		tb.Fatalf("gogtest: connect etcd: %v", err)
+	}
+	use(tb, registry[*etcdcli.Client]{etcdcli.Register, etcdcli.Unregister, etcdcli.Lookup}, opts.Name, c)
+	return c, e
+}
@@ -0,0 +1,71 @@
+// Package gogtest 为单元测试提供各客户端包的替身：sqlite 代替 MySQL/PostgreSQL、miniredis、内嵌的 nats-server、
+// 内存 MQTT Broker、内嵌 etcd 和 S3 兼容的假服务器，并能捕获 zlog 的日志输出。
+//
+// 替身默认注册为对应客户端包的默认实例，业务代码中的 mysqlcli.DB(ctx)、rediscli.Get、natscli.Pub、
+// mqttcli.Publish 等包级函数无需修改即可使用；测试结束时关闭替身并恢复原来的实例。
+// 替身会修改全局状态，使用替身的测试不能调用 t.Parallel
+package gogtest
+
+import (
+	"net"
+	"testing"
+)
+
+type Options struct {
+	Name string // 注册的实例名称，默认为客户端包的默认实例
+}
+
+type Option func(*Options)
+
+// WithName 注册为命名实例，用于替换 Use(name) 获取的实例
+func WithName(name string) Option {
+	return func(options *Options) {
+		options.Name = name
+	}
+}
+
+func newOptions(defaultName string, options []Option) Options {
+	opts := Options{Name: defaultName}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	if opts.Name == "" {
+		opts.Name = defaultName
+	}
+	return opts
+}
+
+// registry 客户端包的实例注册函数
+type registry[C any] struct {
+	register   func(name string, c C)
+	unregister func(name string) (C, bool)
+	lookup     func(name string) (C, bool)
+}
+
+// use 注册替身，测试结束时关闭替身并恢复原来的实例
+func use[C interface{ Close() error }](tb testing.TB, r registry[C], name string, c C) {
+	tb.Helper()
+	prev, ok := r.lookup(name)
+	r.register(name, c)
+	tb.Cleanup(func() {
+		if ok {
+			r.register(name, prev)
+		} else {
+			r.unregister(name)
+		}
+		_ = c.Close()
+	})
+}
+
+// freeAddr 获取一个空闲的本地地址，用于不支持监听 0 端口的服务
+func freeAddr(tb testing.TB) string {
+	tb.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("gogtest: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
@@ -0,0 +1,120 @@
+package gogtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chenparty/gog/zlog"
+)
+
+// Logs 捕获的日志，并发安全
+type Logs struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Entry 一条日志，字段与 zlog 输出的 JSON 一致
+type Entry map[string]any
+
+// CaptureLogs 将全局日志输出到内存，level 为空时使用 debug；测试结束时恢复为标准输出
+func CaptureLogs(tb testing.TB, level string) *Logs {
+	tb.Helper()
+	if level == "" {
+		level = "debug"
+	}
+	logs := &Logs{}
+	if err := zlog.NewLogLoggerE("stdout", level, zlog.WithWriter(logs)); err != nil {
+		tb.Fatalf("gogtest: %v", err)
+	}
+	tb.Cleanup(func() { zlog.NewLogLogger("stdout", "debug") })
+	return logs
+}
+
+// Write 实现 io.Writer，zerolog 每次写入一条完整的日志
+func (l *Logs) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+// String 原始的日志输出
+func (l *Logs) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
+
+// Reset 清空已捕获的日志
+func (l *Logs) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf.Reset()
+}
+
+// Entries 解析所有日志，无法解析的行被忽略
+func (l *Logs) Entries() (entries []Entry) {
+	for _, line := range strings.Split(l.String(), "\n") {
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if json.Unmarshal([]byte(line), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return
+}
+
+// Find 查找消息为 msg 的日志
+func (l *Logs) Find(msg string) (entries []Entry) {
+	for _, e := range l.Entries() {
+		if e.Message() == msg {
+			entries = append(entries, e)
+		}
+	}
+	return
+}
+
+// Contains 是否存在消息为 msg 的日志
+func (l *Logs) Contains(msg string) bool {
+	return len(l.Find(msg)) > 0
+}
+
+// WithTraceID 查找 trace_id 为 traceID 的日志，用于检查链路上的日志是否都带有同一个 trace_id
+func (l *Logs) WithTraceID(traceID string) (entries []Entry) {
+	for _, e := range l.Entries() {
+		if e.TraceID() == traceID {
+			entries = append(entries, e)
+		}
+	}
+	return
+}
+
+// Str 字符串字段的值，不存在或不是字符串时返回空
+func (e Entry) Str(key string) string {
+	s, _ := e[key].(string)
+	return s
+}
+
+// Level 日志级别，如 "info"
+func (e Entry) Level() string {
+	return e.Str("level")
+}
+
+// Message 日志消息
+func (e Entry) Message() string {
+	return e.Str("message")
+}
+
+// TraceID 日志的 trace_id，由 zlog 从 ctx 中读取
+func (e Entry) TraceID() string {
+	return e.Str("trace_no")
+}
+
+// Err 错误信息
+func (e Entry) Err() string {
+	return e.Str("error")
+}
@@ -0,0 +1,32 @@
+package gogtest
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chenparty/gog/client/miniocli"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+// MinIO 启动内存中的 S3 兼容服务并创建 miniocli 的替身，buckets 为预先创建的桶
+func MinIO(tb testing.TB, buckets []string, options ...Option) *miniocli.Instance {
+	tb.Helper()
+	opts := newOptions(miniocli.DefaultName, options)
+	backend := s3mem.New()
+	for _, bucket := range buckets {
+		if err := backend.CreateBucket(bucket); err != nil {
+			tb.Fatalf("gogtest: create bucket %s: %v", bucket, err)
+		}
+	}
+	srv := httptest.NewServer(gofakes3.New(backend).Server())
+	tb.Cleanup(srv.Close)
+
+	c, err := miniocli.NewContext(tb.Context(), strings.TrimPrefix(srv.URL, "http://"), miniocli.WithAccess("gogtest", "gogtest"), miniocli.WithSSL(false))
+	if err != nil {
+		tb.Fatalf("gogtest: connect s3: %v", err)
+	}
+	use(tb, registry[*miniocli.Instance]{miniocli.Register, miniocli.Unregister, miniocli.Lookup}, opts.Name, c)
+	return c
+}
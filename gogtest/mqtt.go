@@ -0,0 +1,41 @@
+package gogtest
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/chenparty/gog/client/mqttcli"
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// MQTT 启动内存中的 MQTT Broker 并创建 mqttcli 的替身，Broker 允许所有连接；
+// 返回的 Server 可通过 Publish 模拟设备上报，或通过 Subscribe 检查业务代码发布的消息
+func MQTT(tb testing.TB, options ...Option) (*mqttcli.Client, *mqtt.Server) {
+	tb.Helper()
+	opts := newOptions(mqttcli.DefaultName, options)
+	broker := mqtt.New(&mqtt.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := broker.AddHook(new(auth.AllowHook), nil); err != nil {
+		tb.Fatalf("gogtest: mqtt broker: %v", err)
+	}
+	ln := listeners.NewTCP(listeners.Config{ID: "gogtest", Address: "127.0.0.1:0"})
+	if err := broker.AddListener(ln); err != nil {
+		tb.Fatalf("gogtest: mqtt broker: %v", err)
+	}
+	if err := broker.Serve(); err != nil {
+		tb.Fatalf("gogtest: mqtt broker: %v", err)
+	}
+	tb.Cleanup(func() { _ = broker.Close() })
+
+	c, err := mqttcli.NewContext(tb.Context(), "tcp://"+ln.Address())
+	if err != nil {
+		tb.Fatalf("gogtest: connect mqtt broker: %v", err)
+	}
+	use(tb, registry[*mqttcli.Client]{mqttcli.Register, mqttcli.Unregister, mqttcli.Lookup}, opts.Name, c)
+	return c, broker
+}
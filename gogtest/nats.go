@@ -0,0 +1,41 @@
+package gogtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chenparty/gog/client/natscli"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// NATS 启动内嵌的 nats-server（开启 JetStream，数据位于测试的临时目录）并创建 natscli 的替身，
+// 返回的 Server 可用于模拟服务端断开
+func NATS(tb testing.TB, options ...Option) (*natscli.Client, *server.Server) {
+	tb.Helper()
+	opts := newOptions(natscli.DefaultName, options)
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  tb.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		tb.Fatalf("gogtest: create nats-server: %v", err)
+	}
+	go ns.Start()
+	tb.Cleanup(func() {
+		ns.Shutdown()
+		ns.WaitForShutdown()
+	})
+	if !ns.ReadyForConnections(10 * time.Second) {
+		tb.Fatal("gogtest: nats-server not ready")
+	}
+	c, err := natscli.NewContext(tb.Context(), "gogtest", []string{ns.ClientURL()}, natscli.WithJetStream(true))
+	if err != nil {
+		tb.Fatalf("gogtest: connect nats-server: %v", err)
+	}
+	use(tb, registry[*natscli.Client]{natscli.Register, natscli.Unregister, natscli.Lookup}, opts.Name, c)
+	return c, ns
+}
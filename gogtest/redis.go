@@ -0,0 +1,21 @@
+package gogtest
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chenparty/gog/client/rediscli"
+)
+
+// Redis 使用 miniredis 创建 rediscli 的替身，返回的 Miniredis 可用于检查数据或通过 FastForward 模拟过期
+func Redis(tb testing.TB, options ...Option) (*rediscli.Client, *miniredis.Miniredis) {
+	tb.Helper()
+	opts := newOptions(rediscli.DefaultName, options)
+	mr := miniredis.RunT(tb)
+	c, err := rediscli.NewContext(tb.Context(), []string{mr.Addr()})
+	if err != nil {
+		tb.Fatalf("gogtest: connect miniredis: %v", err)
+	}
+	use(tb, registry[*rediscli.Client]{rediscli.Register, rediscli.Unregister, rediscli.Lookup}, opts.Name, c)
+	return c, mr
+}
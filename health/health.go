@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// DefaultTimeout 单个依赖检查的默认超时时间
+const DefaultTimeout = 3 * time.Second
+
+// Checker 依赖检查函数，返回 nil 表示依赖可用
+type Checker func(ctx context.Context) error
+
+// Result 单个依赖的检查结果
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report 所有依赖的检查结果
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Up 所有依赖都可用
+func (r Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+var (
+	checkers = map[string]Checker{}
+	mu       sync.RWMutex // 保护checkers
+	ready    atomic.Bool
+)
+
+func init() {
+	ready.Store(true)
+}
+
+// Register 注册依赖检查，同名检查会被覆盖
+func Register(name string, checker Checker) {
+	mu.Lock()
+	defer mu.Unlock()
+	checkers[name] = checker
+}
+
+// Unregister 移除依赖检查
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(checkers, name)
+}
+
+// Names 已注册的依赖名称
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(checkers))
+	for name := range checkers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetReady 设置服务是否可以接收流量，启动完成前或优雅关闭时置为 false
+func SetReady(r bool) {
+	ready.Store(r)
+}
+
+// IsReady 服务是否可以接收流量
+func IsReady() bool {
+	return ready.Load()
+}
+
+// Check 并发执行所有依赖检查，每个检查单独受 timeout 限制，timeout<=0 时使用 DefaultTimeout
+func Check(ctx context.Context, timeout time.Duration) Report {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	mu.RLock()
+	snapshot := make(map[string]Checker, len(checkers))
+	for name, checker := range checkers {
+		snapshot[name] = checker
+	}
+	mu.RUnlock()
+
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]Result, len(snapshot)),
+	}
+	var (
+		wg      sync.WaitGroup
+		reportM sync.Mutex
+	)
+	for name, checker := range snapshot {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := run(ctx, checker, timeout)
+			reportM.Lock()
+			defer reportM.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func run(ctx context.Context, checker Checker, timeout time.Duration) (result Result) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- panicError{r}
+			}
+		}()
+		done <- checker(ctx)
+	}()
+	var err error
+	// 检查函数不响应 ctx 时也能按时返回
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result.Duration = time.Since(start).String()
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+		return
+	}
+	result.Status = StatusUp
+	return
+}
+
+type panicError struct {
+	v any
+}
+
+func (e panicError) Error() string {
+	return fmt.Sprint("health check panic: ", e.v)
+}
@@ -0,0 +1,268 @@
+// Package inbox 消费端去重：记录已处理的消息 ID，重复投递的消息直接跳过，用于 MQTT QoS 1、JetStream 等至少一次投递的场景。
+//
+//	in := inbox.New("order-worker", inbox.WithStore(inbox.NewRedisStore(nil)), inbox.WithMQTTKey(eventID))
+//	natscli.Consume(ctx, "ORDERS", "order-worker", in.JetStream(handler))
+//	mqttcli.Subscribe("device/+/event", 1, in.MQTT(func(ctx context.Context, id uint16, topic string, payload []byte) error { ... }))
+package inbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/chenparty/gog/client/mqttcli"
+	"github.com/chenparty/gog/client/natscli"
+	"github.com/chenparty/gog/zlog"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/oklog/ulid/v2"
+)
+
+// Status 消息的处理状态
+type Status int
+
+const (
+	StatusNew        Status = iota // 未处理，已记录为处理中
+	StatusInProgress               // 其它消费者正在处理
+	StatusDone                     // 已处理
+)
+
+// Store 保存消息的处理状态
+type Store interface {
+	// Claim 将未处理（或处理中已超过 lockTTL）的消息记录为 token 处理中并返回 StatusNew，否则返回当前状态
+	Claim(ctx context.Context, key, token string, lockTTL time.Duration) (Status, error)
+	// Complete 记录消息已处理，保存 ttl
+	Complete(ctx context.Context, key string, ttl time.Duration) error
+	// Release 删除 token 处理中的记录，消息重新投递时再次处理；记录已过期并被其它消费者获取时不删除
+	Release(ctx context.Context, key, token string) error
+}
+
+// ErrInProgress 相同的消息正在被处理
+var ErrInProgress = errors.New("inbox: message is in progress")
+
+type Options struct {
+	Store   Store         // 默认使用 rediscli 默认实例的 RedisStore
+	TTL     time.Duration // 已处理记录的保存时间，默认 24 小时，需要大于消息可能重复投递的时间范围
+	LockTTL time.Duration // 处理中记录的保存时间，默认 1 分钟，需要大于 handler 的处理时间
+
+	JetStreamKey func(msg jetstream.Msg) string            // 默认 JetStreamMsgID
+	NATSKey      func(msg *nats.Msg) string                // 默认 NATSMsgID
+	MQTTKey      func(topic string, payload []byte) string // 没有默认值，使用 MQTT 时必须设置
+}
+
+type Option func(*Options)
+
+// WithStore 设置存储
+func WithStore(store Store) Option {
+	return func(options *Options) {
+		options.Store = store
+	}
+}
+
+// WithTTL 设置已处理记录的保存时间
+func WithTTL(ttl time.Duration) Option {
+	return func(options *Options) {
+		options.TTL = ttl
+	}
+}
+
+// WithLockTTL 设置处理中记录的保存时间
+func WithLockTTL(ttl time.Duration) Option {
+	return func(options *Options) {
+		options.LockTTL = ttl
+	}
+}
+
+// WithJetStreamKey 设置 JetStream 消息的去重 key，返回空时不去重
+func WithJetStreamKey(fn func(msg jetstream.Msg) string) Option {
+	return func(options *Options) {
+		options.JetStreamKey = fn
+	}
+}
+
+// WithNATSKey 设置 NATS 消息的去重 key，返回空时不去重
+func WithNATSKey(fn func(msg *nats.Msg) string) Option {
+	return func(options *Options) {
+		options.NATSKey = fn
+	}
+}
+
+// WithMQTTKey 设置 MQTT 消息的去重 key，返回空时不去重，如从 payload 中解析业务 ID。
+// MQTT 消息没有消息 ID，必须设置后才能使用 MQTT
+func WithMQTTKey(fn func(topic string, payload []byte) string) Option {
+	return func(options *Options) {
+		options.MQTTKey = fn
+	}
+}
+
+// Inbox 消费端去重
+type Inbox struct {
+	name string
+	opts Options
+}
+
+// New 创建去重器，name 区分消费者，同一条消息被不同的消费者处理时互不影响
+func New(name string, options ...Option) *Inbox {
+	opts := Options{
+		TTL:          24 * time.Hour,
+		LockTTL:      time.Minute,
+		JetStreamKey: JetStreamMsgID,
+		NATSKey:      NATSMsgID,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	if opts.Store == nil {
+		opts.Store = NewRedisStore(nil)
+	}
+	return &Inbox{name: name, opts: opts}
+}
+
+// Process 以 key 去重执行 fn：已处理时跳过并返回 duplicate 为 true；其它消费者正在处理时返回 ErrInProgress；
+// fn 返回错误时删除处理中的记录，消息重新投递时再次处理。key 为空时直接执行 fn
+func (in *Inbox) Process(ctx context.Context, key string, fn func(ctx context.Context) error) (duplicate bool, err error) {
+	if key == "" {
+		return false, fn(ctx)
+	}
+	key = in.name + ":" + key
+	token := ulid.Make().String()
+	status, err := in.opts.Store.Claim(ctx, key, token, in.opts.LockTTL)
+	if err != nil {
+		return
+	}
+	switch status {
+	case StatusDone:
+		zlog.Debug().Ctx(ctx).Str("key", key).Msg("重复消息，跳过")
+		return true, nil
+	case StatusInProgress:
+		return false, ErrInProgress
+	}
+	completed := false
+	defer func() {
+		// fn 返回错误或 panic 时删除处理中的记录
+		if !completed {
+			if e := in.opts.Store.Release(context.WithoutCancel(ctx), key, token); e != nil {
+				zlog.Error().Ctx(ctx).Err(e).Str("key", key).Msg("inbox删除处理中记录失败")
+			}
+		}
+	}()
+	if err = fn(ctx); err != nil {
+		return
+	}
+	completed = true
+	if e := in.opts.Store.Complete(context.WithoutCancel(ctx), key, in.opts.TTL); e != nil {
+		zlog.Error().Ctx(ctx).Err(e).Str("key", key).Msg("inbox记录已处理失败")
+	}
+	return
+}
+
+// JetStream 包装 JetStream 消息的 handler：重复的消息直接确认，其它消费者正在处理时延迟重新投递
+func (in *Inbox) JetStream(handler natscli.JsMsgHandler) natscli.JsMsgHandler {
+	return func(ctx context.Context, msg jetstream.Msg) error {
+		_, err := in.Process(ctx, in.opts.JetStreamKey(msg), func(ctx context.Context) error {
+			return handler(ctx, msg)
+		})
+		if errors.Is(err, ErrInProgress) {
+			return natscli.RetryAfter(err, in.opts.LockTTL)
+		}
+		return err
+	}
+}
+
+// NATSHandler 处理 NATS 消息，返回错误时删除处理记录
+type NATSHandler func(ctx context.Context, msg *nats.Msg) error
+
+// MQTTHandler 处理 MQTT 消息，返回错误时删除处理记录
+type MQTTHandler func(ctx context.Context, id uint16, topic string, payload []byte) error
+
+// processWait 同 Process，其它消费者正在处理时等待：对方处理成功后作为重复消息跳过，失败或处理中的记录过期后再次处理。
+// 用于没有重新投递机制的订阅，直接丢弃会在对方处理失败时丢失消息；最多等待 LockTTL+1s（默认 61 秒），
+// 期间阻塞调用方的 goroutine
+func (in *Inbox) processWait(ctx context.Context, key string, fn func(ctx context.Context) error) (duplicate bool, err error) {
+	deadline := time.Now().Add(in.opts.LockTTL + time.Second)
+	wait := 50 * time.Millisecond
+	for {
+		duplicate, err = in.Process(ctx, key, fn)
+		if !errors.Is(err, ErrInProgress) || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(wait)
+		wait = min(wait*2, time.Second)
+	}
+}
+
+// NATS 包装 NATS 消息的 handler，用于 natscli.Sub、QueueSub、JsSub 等订阅；handler 返回错误时删除处理记录，
+// 消息再次投递时重新处理。相同的消息正在处理时等待其结果，最多 LockTTL+1s（默认 61 秒），
+// 期间阻塞该订阅的回调 goroutine，后续消息排队
+func (in *Inbox) NATS(handler NATSHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		ctx := zlog.NewTraceContext()
+		_, err := in.processWait(ctx, in.opts.NATSKey(msg), func(ctx context.Context) error {
+			return handler(ctx, msg)
+		})
+		if err != nil {
+			zlog.Warn().Ctx(ctx).Err(err).Str("subj", msg.Subject).Msg("inbox处理NATS消息失败")
+		}
+	}
+}
+
+// MQTT 包装 MQTT 消息的 handler，用于 mqttcli.Subscribe；未设置 WithMQTTKey 时 panic。
+// handler 返回错误时删除处理记录，消息再次投递时重新处理。相同的消息正在处理时等待其结果，
+// 最多 LockTTL+1s（默认 61 秒），期间阻塞 paho 的消息分发 goroutine，该连接的所有订阅都不会收到消息
+func (in *Inbox) MQTT(handler MQTTHandler) mqttcli.MsgHandler {
+	if in.opts.MQTTKey == nil {
+		panic("inbox: MQTT requires WithMQTTKey")
+	}
+	return func(id uint16, topic string, payload []byte) {
+		ctx := zlog.NewTraceContext()
+		_, err := in.processWait(ctx, in.opts.MQTTKey(topic, payload), func(ctx context.Context) error {
+			return handler(ctx, id, topic, payload)
+		})
+		if err != nil {
+			zlog.Warn().Ctx(ctx).Err(err).Str("topic", topic).Msg("inbox处理MQTT消息失败")
+		}
+	}
+}
+
+// JetStreamMsgID 使用消息头 Nats-Msg-Id，没有时使用流名称和序号
+func JetStreamMsgID(msg jetstream.Msg) string {
+	if id := msg.Headers().Get(jetstream.MsgIDHeader); id != "" {
+		return id
+	}
+	return JetStreamSequence(msg)
+}
+
+// JetStreamSequence 使用流名称和序号，同一条消息重新投递时序号不变
+func JetStreamSequence(msg jetstream.Msg) string {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return ""
+	}
+	return meta.Stream + ":" + strconv.FormatUint(meta.Sequence.Stream, 10)
+}
+
+// NATSMsgID 使用消息头 Nats-Msg-Id，没有时对 JetStream 消息使用流名称和序号
+func NATSMsgID(msg *nats.Msg) string {
+	if id := msg.Header.Get(nats.MsgIdHdr); id != "" {
+		return id
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		return meta.Stream + ":" + strconv.FormatUint(meta.Sequence.Stream, 10)
+	}
+	return ""
+}
+
+// MQTTPayloadHash 使用主题和消息内容的 SHA-256，内容相同的消息视为重复。
+// 合法的重复消息（如相同的状态上报）在 TTL 内也会被丢弃，只适用于内容不会重复的消息，或配合较短的 WithTTL 使用
+func MQTTPayloadHash(topic string, payload []byte) string {
+	h := sha256.New()
+	h.Write([]byte(topic))
+	h.Write([]byte{0})
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
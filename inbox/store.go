@@ -0,0 +1,142 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chenparty/gog/client/rediscli"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	valueProcessing = "processing"
+	valueDone       = "done"
+)
+
+// RedisStore 使用 Redis 保存处理状态，key 为 "gog:inbox:<消费者>:<消息 key>"
+type RedisStore struct {
+	client *rediscli.Client
+	prefix string
+}
+
+// NewRedisStore 创建 Redis 存储，c 为 nil 时在使用时获取 rediscli 的默认实例
+func NewRedisStore(c *rediscli.Client) *RedisStore {
+	return &RedisStore{client: c, prefix: "gog:inbox:"}
+}
+
+func (s *RedisStore) cli() *rediscli.Client {
+	if s.client != nil {
+		return s.client
+	}
+	return rediscli.Default()
+}
+
+func (s *RedisStore) redis() redis.UniversalClient {
+	return s.cli().Redis()
+}
+
+func (s *RedisStore) Claim(ctx context.Context, key, token string, lockTTL time.Duration) (Status, error) {
+	ok, err := s.redis().SetNX(ctx, s.prefix+key, valueProcessing+":"+token, lockTTL).Result()
+	if err != nil || ok {
+		return StatusNew, err
+	}
+	val, err := s.redis().Get(ctx, s.prefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		// 处理中的记录刚好过期，下次投递时重新获取
+		return StatusInProgress, nil
+	}
+	if err != nil {
+		return StatusNew, err
+	}
+	if val == valueDone {
+		return StatusDone, nil
+	}
+	return StatusInProgress, nil
+}
+
+func (s *RedisStore) Complete(ctx context.Context, key string, ttl time.Duration) error {
+	return s.redis().Set(ctx, s.prefix+key, valueDone, ttl).Err()
+}
+
+func (s *RedisStore) Release(ctx context.Context, key, token string) error {
+	_, err := s.cli().DelIfEqual(ctx, s.prefix+key, valueProcessing+":"+token)
+	return err
+}
+
+// Message inbox 表中的记录
+type Message struct {
+	ID        string    `gorm:"primaryKey;size:191"`
+	Status    string    `gorm:"size:16;not null"`
+	Token     string    `gorm:"size:32"` // 处理中记录的持有者
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// TableName 表名
+func (Message) TableName() string {
+	return "inbox_message"
+}
+
+// DBStore 使用数据库（MySQL、PostgreSQL）保存处理状态，过期的记录通过 Cleanup 删除
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore 创建数据库存储，如 inbox.NewDBStore(mysqlcli.Default().DB(ctx))
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// Migrate 创建或更新 inbox 表
+func (s *DBStore) Migrate() error {
+	return s.db.AutoMigrate(&Message{})
+}
+
+func (s *DBStore) Claim(ctx context.Context, key, token string, lockTTL time.Duration) (Status, error) {
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&Message{ID: key, Status: valueProcessing, Token: token, ExpiresAt: now.Add(lockTTL)})
+	if res.Error != nil {
+		return StatusNew, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return StatusNew, nil
+	}
+	// 已存在的记录过期后可以重新处理
+	res = db.Model(&Message{}).Where("id = ? AND expires_at < ?", key, now).
+		Updates(map[string]any{"status": valueProcessing, "token": token, "expires_at": now.Add(lockTTL)})
+	if res.Error != nil {
+		return StatusNew, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return StatusNew, nil
+	}
+	var m Message
+	if err := db.Where("id = ?", key).Take(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return StatusInProgress, nil
+		}
+		return StatusNew, err
+	}
+	if m.Status == valueDone {
+		return StatusDone, nil
+	}
+	return StatusInProgress, nil
+}
+
+func (s *DBStore) Complete(ctx context.Context, key string, ttl time.Duration) error {
+	return s.db.WithContext(ctx).Model(&Message{}).Where("id = ?", key).
+		Updates(map[string]any{"status": valueDone, "expires_at": time.Now().Add(ttl)}).Error
+}
+
+func (s *DBStore) Release(ctx context.Context, key, token string) error {
+	return s.db.WithContext(ctx).Where("id = ? AND status = ? AND token = ?", key, valueProcessing, token).Delete(&Message{}).Error
+}
+
+// Cleanup 删除过期的记录，返回删除的记录数，可以定时执行
+func (s *DBStore) Cleanup(ctx context.Context) (int64, error) {
+	res := s.db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&Message{})
+	return res.RowsAffected, res.Error
+}
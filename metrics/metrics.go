@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace 所有指标的统一前缀，命名规则：gog_<子系统>_<指标名>_<单位>
+const Namespace = "gog"
+
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// Registry gog 使用的指标注册中心，业务自定义指标也可以注册到这里，由同一个 /metrics 输出
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTP 服务端（gin）
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "http", Name: "requests_total",
+		Help: "HTTP 请求总数",
+	}, []string{"method", "route", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace, Subsystem: "http", Name: "request_duration_seconds",
+		Help:    "HTTP 请求耗时",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	httpInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace, Subsystem: "http", Name: "requests_in_flight",
+		Help: "正在处理中的 HTTP 请求数",
+	}, []string{"method", "route"})
+
+	// HTTP 客户端（httpcli）
+	httpClientRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "httpcli", Name: "requests_total",
+		Help: "对外 HTTP 调用总数",
+	}, []string{"method", "host", "status"})
+	httpClientDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace, Subsystem: "httpcli", Name: "request_duration_seconds",
+		Help:    "对外 HTTP 调用耗时",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "host"})
+
+	// NATS
+	natsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "nats", Name: "published_total",
+		Help: "NATS 发布消息数，发布主题可能含 ID 等任意值，不作为标签",
+	}, []string{"result"})
+	natsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "nats", Name: "received_total",
+		Help: "NATS 订阅收到的消息数，subject 为订阅时的主题（可能含通配符）",
+	}, []string{"subject"})
+	natsSlowConsumers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "nats", Name: "slow_consumers_total",
+		Help: "NATS 订阅积压超过上限（慢消费者）的次数，超出的消息被丢弃",
+	}, []string{"subject"})
+
+	// MQTT
+	mqttPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "mqtt", Name: "published_total",
+		Help: "MQTT 发布消息数，发布主题可能含设备 ID 等任意值，不作为标签",
+	}, []string{"result"})
+	mqttReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "mqtt", Name: "received_total",
+		Help: "MQTT 订阅收到的消息数，topic 为订阅时的主题过滤器（可能含通配符）",
+	}, []string{"topic"})
+
+	// GORM
+	gormDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace, Subsystem: "gorm", Name: "query_duration_seconds",
+		Help:    "GORM 语句执行耗时",
+		Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"dialect", "operation", "table", "result"})
+
+	// Redis
+	redisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace, Subsystem: "redis", Name: "command_duration_seconds",
+		Help:    "Redis 命令执行耗时",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, httpInFlight,
+		httpClientRequests, httpClientDuration,
+		natsPublished, natsReceived, natsSlowConsumers,
+		mqttPublished, mqttReceived,
+		gormDuration,
+		redisDuration,
+	)
+}
+
+// Handler 输出 Registry 中所有指标的 http.Handler
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Result 将 err 转换为 result 标签值
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultOK
+}
+
+// HTTPInFlight 返回指定路由的处理中请求数
+func HTTPInFlight(method, route string) prometheus.Gauge {
+	return httpInFlight.WithLabelValues(method, route)
+}
+
+// ObserveHTTPRequest 记录一次 HTTP 请求，route 为路由模板（如 /v1/user/:id）
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// ObserveHTTPClient 记录一次对外 HTTP 调用，请求失败（无响应）时 status 为 0
+func ObserveHTTPClient(method, host string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	if status == 0 {
+		code = ResultError
+	}
+	httpClientRequests.WithLabelValues(method, host, code).Inc()
+	httpClientDuration.WithLabelValues(method, host).Observe(duration.Seconds())
+}
+
+// IncNATSPublish 记录一次 NATS 发布
+func IncNATSPublish(err error) {
+	natsPublished.WithLabelValues(Result(err)).Inc()
+}
+
+// IncNATSReceive 记录一条 NATS 订阅消息
+func IncNATSReceive(subject string) {
+	natsReceived.WithLabelValues(subject).Inc()
+}
+
+// IncNATSSlowConsumer 记录一次 NATS 慢消费者，subject 为订阅时的主题
+func IncNATSSlowConsumer(subject string) {
+	natsSlowConsumers.WithLabelValues(subject).Inc()
+}
+
+// IncMQTTPublish 记录一次 MQTT 发布
+func IncMQTTPublish(err error) {
+	mqttPublished.WithLabelValues(Result(err)).Inc()
+}
+
+// IncMQTTReceive 记录一条 MQTT 订阅消息，topic 为订阅时的主题过滤器
+func IncMQTTReceive(topic string) {
+	mqttReceived.WithLabelValues(topic).Inc()
+}
+
+// ObserveGorm 记录一次 GORM 语句执行
+func ObserveGorm(dialect, operation, table string, duration time.Duration, err error) {
+	gormDuration.WithLabelValues(dialect, operation, table, Result(err)).Observe(duration.Seconds())
+}
+
+// ObserveRedis 记录一次 Redis 命令执行
+func ObserveRedis(command string, duration time.Duration, err error) {
+	redisDuration.WithLabelValues(command, Result(err)).Observe(duration.Seconds())
+}
@@ -0,0 +1,98 @@
+// Package outbox 事务发件箱：事件与业务数据在同一个 gorm 事务中写入 outbox 表，
+// 由 Relay 发布到 JetStream，避免提交后、发布前进程退出导致事件丢失。
+//
+//	mysqlcli.StartTransaction(ctx, func(tx *gorm.DB) error {
+//		if err := tx.Create(&order).Error; err != nil {
+//			return err
+//		}
+//		return outbox.Publish(tx, "orders.created", data)
+//	})
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chenparty/gog/zlog"
+	"github.com/chenparty/gog/zlog/ginplugin"
+	"github.com/nats-io/nats.go"
+	"github.com/oklog/ulid/v2"
+	"gorm.io/gorm"
+)
+
+// Event outbox 表中的事件，MySQL 和 PostgreSQL 通过 Migrate 或 AutoMigrate(&outbox.Event{}) 建表
+type Event struct {
+	ID        uint64      `gorm:"primaryKey;autoIncrement"`
+	MsgID     string      `gorm:"size:64;not null;uniqueIndex"` // 发布时的 Nats-Msg-Id，JetStream 在去重窗口内丢弃重复的消息
+	Subject   string      `gorm:"size:255;not null"`
+	Header    nats.Header `gorm:"serializer:json;type:text"`
+	Data      []byte
+	Attempts  int        `gorm:"not null;default:0"` // 发布失败的次数
+	LastError string     `gorm:"size:1024"`
+	SentAt    *time.Time `gorm:"index"` // 发布成功的时间，为空时未发布
+	FailedAt  *time.Time `gorm:"index"` // 失败次数达到 MaxAttempts 后放弃的时间，不为空时不再发布
+	CreatedAt time.Time
+}
+
+// TableName 表名
+func (Event) TableName() string {
+	return "outbox_event"
+}
+
+// Migrate 创建或更新 outbox 表
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Event{})
+}
+
+type PublishOptions struct {
+	MsgID  string      // 消息 ID，默认生成 ULID；使用业务 ID 时相同的事件只会写入一次
+	Header nats.Header // 消息头
+}
+
+type PublishOption func(*PublishOptions)
+
+// WithMsgID 设置消息 ID
+func WithMsgID(id string) PublishOption {
+	return func(options *PublishOptions) {
+		options.MsgID = id
+	}
+}
+
+// WithHeader 添加消息头
+func WithHeader(key, value string) PublishOption {
+	return func(options *PublishOptions) {
+		if options.Header == nil {
+			options.Header = nats.Header{}
+		}
+		options.Header.Add(key, value)
+	}
+}
+
+// Publish 在事务 tx 中写入事件，事务提交后由 Relay 发布；tx 的 ctx 中的 trace_id 随消息头 Z-Request-ID 传递
+func Publish(tx *gorm.DB, subj string, data []byte, options ...PublishOption) error {
+	if subj == "" {
+		return errors.New("outbox: subject is required")
+	}
+	var opts PublishOptions
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	if opts.MsgID == "" {
+		opts.MsgID = ulid.Make().String()
+	}
+	header := nats.Header{}
+	for k, v := range opts.Header {
+		header[k] = v
+	}
+	ctx := context.Background()
+	if tx.Statement != nil && tx.Statement.Context != nil {
+		ctx = tx.Statement.Context
+	}
+	if traceID := zlog.TraceIDFromContext(ctx); traceID != "" && header.Get(ginplugin.HeaderRequestID) == "" {
+		header.Set(ginplugin.HeaderRequestID, traceID)
+	}
+	return tx.Create(&Event{MsgID: opts.MsgID, Subject: subj, Header: header, Data: data}).Error
+}
@@ -0,0 +1,275 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chenparty/gog/client/etcdcli"
+	"github.com/chenparty/gog/client/natscli"
+	"github.com/chenparty/gog/zlog"
+	"github.com/chenparty/gog/zlog/ginplugin"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"gorm.io/gorm"
+)
+
+type Options struct {
+	NATS      *natscli.Client // 默认使用 natscli 的默认实例
+	Etcd      *etcdcli.Client // 默认使用 etcdcli 的默认实例，为 nil 时不加锁，只能运行一个实例
+	LockKey   string          // etcd 锁的 key，默认 "/gog/outbox/lock"，多个 outbox 表使用不同的 key
+	LockTTL   int             // etcd 锁的租约时间（秒），默认 10；持有锁的实例失联超过该时间后由其它实例接管
+	Interval  time.Duration   // 没有待发布事件时的轮询间隔，默认 1 秒
+	BatchSize int             // 每次发布的最大事件数，默认 100
+	Retention time.Duration   // 已发布事件的保留时间，默认 7 天，小于 0 时不清理
+	// MaxAttempts 单个事件的最大发布次数，达到后标记为失败（failed_at）并跳过，不再阻塞后续事件；
+	// 默认 0 不限制。NATS 不可用时所有事件都会失败，设置过小会在故障期间放弃大量事件
+	MaxAttempts int
+}
+
+type Option func(*Options)
+
+// WithNATS 使用指定的 NATS 客户端
+func WithNATS(c *natscli.Client) Option {
+	return func(options *Options) {
+		options.NATS = c
+	}
+}
+
+// WithEtcd 使用指定的 etcd 客户端加锁
+func WithEtcd(c *etcdcli.Client) Option {
+	return func(options *Options) {
+		options.Etcd = c
+	}
+}
+
+// WithLock 设置 etcd 锁的 key 和租约时间（秒）
+func WithLock(key string, ttl int) Option {
+	return func(options *Options) {
+		options.LockKey = key
+		options.LockTTL = ttl
+	}
+}
+
+// WithInterval 设置轮询间隔
+func WithInterval(interval time.Duration) Option {
+	return func(options *Options) {
+		options.Interval = interval
+	}
+}
+
+// WithBatchSize 设置每次发布的最大事件数
+func WithBatchSize(n int) Option {
+	return func(options *Options) {
+		options.BatchSize = n
+	}
+}
+
+// WithRetention 设置已发布事件的保留时间，小于 0 时不清理
+func WithRetention(retention time.Duration) Option {
+	return func(options *Options) {
+		options.Retention = retention
+	}
+}
+
+// WithMaxAttempts 设置单个事件的最大发布次数，0 不限制
+func WithMaxAttempts(n int) Option {
+	return func(options *Options) {
+		options.MaxAttempts = n
+	}
+}
+
+// Relay 将 outbox 表中未发布的事件按写入顺序发布到 JetStream
+type Relay struct {
+	db   *gorm.DB
+	opts Options
+}
+
+// NewRelay 创建发布器，db 为写入事件的数据库，如 mysqlcli.Default().DB(ctx)
+func NewRelay(db *gorm.DB, options ...Option) *Relay {
+	opts := Options{
+		LockKey:   "/gog/outbox/lock",
+		LockTTL:   10,
+		Interval:  time.Second,
+		BatchSize: 100,
+		Retention: 7 * 24 * time.Hour,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	if opts.NATS == nil {
+		opts.NATS = natscli.Default()
+	}
+	if opts.Etcd == nil {
+		opts.Etcd = etcdcli.Default()
+	}
+	return &Relay{db: db, opts: opts}
+}
+
+// Run 持续发布事件直到 ctx 取消，可作为 app.Job 运行。多个实例同时运行时，只有获得 etcd 锁的实例发布，
+// 其它实例等待；锁的租约失效时停止发布并重新竞争。
+// 发布成功但标记失败时事件会再次发布，由 Nats-Msg-Id 在流的去重窗口（默认 2 分钟）内去重
+func (r *Relay) Run(ctx context.Context) error {
+	if r.opts.Etcd == nil {
+		zlog.Warn().Msg("outbox未配置etcd，不加锁运行")
+		r.relay(ctx, nil)
+		return nil
+	}
+	retry := time.Second
+	for ctx.Err() == nil {
+		lock, err := r.opts.Etcd.NewLocker(r.opts.LockTTL)
+		if err == nil {
+			if err = lock.Lock(ctx, r.opts.LockKey); err != nil {
+				_ = lock.Close()
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			zlog.Warn().Err(err).Str("key", r.opts.LockKey).Dur("retry", retry).Msg("outbox获取锁失败")
+			select {
+			case <-ctx.Done():
+			case <-time.After(retry):
+			}
+			retry = min(retry*2, 30*time.Second)
+			continue
+		}
+		retry = time.Second
+		zlog.Info().Str("key", r.opts.LockKey).Msg("outbox获得发布锁")
+		r.relay(ctx, lock.Done())
+
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = etcdcli.UnlockAndClose(unlockCtx, lock)
+		cancel()
+		if ctx.Err() == nil {
+			zlog.Warn().Str("key", r.opts.LockKey).Msg("outbox发布锁已失效，重新获取")
+		}
+	}
+	return nil
+}
+
+// relay 轮询发布，直到 ctx 取消或锁失效
+func (r *Relay) relay(ctx context.Context, lost <-chan struct{}) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	var lastCleanup time.Time
+	for {
+		n, err := r.flush(ctx, lost)
+		if err != nil && ctx.Err() == nil {
+			zlog.Error().Err(err).Msg("outbox发布失败")
+		}
+		if r.opts.Retention > 0 && time.Since(lastCleanup) > time.Hour {
+			lastCleanup = time.Now()
+			r.cleanup(ctx)
+		}
+		// 一批已满时继续发布，不等待下次轮询
+		if err == nil && n == r.opts.BatchSize {
+			select {
+			case <-ctx.Done():
+				return
+			case <-lost:
+				return
+			default:
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-lost:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush 发布一批未发布的事件，返回发布成功的事件数；某个事件发布失败时停止，保证事件的发布顺序。
+// 设置了 MaxAttempts 时，失败次数达到上限的事件标记为失败后跳过，继续发布后续事件
+func (r *Relay) Flush(ctx context.Context) (n int, err error) {
+	return r.flush(ctx, nil)
+}
+
+// flush 同 Flush，每个事件发布前检查锁是否失效，失效时停止，避免与接管的实例同时发布
+func (r *Relay) flush(ctx context.Context, lost <-chan struct{}) (n int, err error) {
+	var events []Event
+	err = r.db.WithContext(ctx).Where("sent_at IS NULL AND failed_at IS NULL").Order("id").Limit(r.opts.BatchSize).Find(&events).Error
+	if err != nil {
+		return
+	}
+	for _, e := range events {
+		select {
+		case <-lost:
+			return
+		default:
+		}
+		if err = r.publish(ctx, e); err != nil {
+			updates := map[string]any{
+				"attempts":   gorm.Expr("attempts + 1"),
+				"last_error": truncate(err.Error(), 1024),
+			}
+			giveUp := r.opts.MaxAttempts > 0 && e.Attempts+1 >= r.opts.MaxAttempts
+			if giveUp {
+				updates["failed_at"] = time.Now()
+			}
+			if updateErr := r.db.WithContext(ctx).Model(&Event{}).Where("id = ?", e.ID).Updates(updates).Error; updateErr != nil && giveUp {
+				return n, fmt.Errorf("outbox: mark event %d failed: %w", e.ID, updateErr)
+			}
+			if giveUp {
+				zlog.Error().Err(err).Uint64("id", e.ID).Str("msg_id", e.MsgID).Str("subj", e.Subject).
+					Int("attempts", e.Attempts+1).Msg("outbox事件发布失败次数达到上限，已放弃")
+				err = nil
+				continue
+			}
+			return n, fmt.Errorf("outbox: publish event %d: %w", e.ID, err)
+		}
+		if err = r.db.WithContext(ctx).Model(&Event{}).Where("id = ?", e.ID).Update("sent_at", time.Now()).Error; err != nil {
+			return n, fmt.Errorf("outbox: mark event %d sent: %w", e.ID, err)
+		}
+		n++
+	}
+	return
+}
+
+func (r *Relay) publish(ctx context.Context, e Event) error {
+	msg := nats.NewMsg(e.Subject)
+	msg.Data = e.Data
+	for k, v := range e.Header {
+		msg.Header[k] = v
+	}
+	msg.Header.Set(jetstream.MsgIDHeader, e.MsgID)
+	if traceID := msg.Header.Get(ginplugin.HeaderRequestID); traceID != "" {
+		ctx = zlog.ContextWithValue(ctx, traceID)
+	}
+	_, err := r.opts.NATS.JsPublishMsg(ctx, msg)
+	return err
+}
+
+// RetryFailed 将标记为失败的事件恢复为未发布，失败次数清零，返回恢复的事件数；
+// 恢复的事件按 ID 顺序发布，可能晚于其后写入的事件
+func (r *Relay) RetryFailed(ctx context.Context) (n int64, err error) {
+	res := r.db.WithContext(ctx).Model(&Event{}).Where("sent_at IS NULL AND failed_at IS NOT NULL").
+		Updates(map[string]any{"failed_at": nil, "attempts": 0})
+	return res.RowsAffected, res.Error
+}
+
+// cleanup 删除超过保留时间的已发布事件
+func (r *Relay) cleanup(ctx context.Context) {
+	res := r.db.WithContext(ctx).Where("sent_at < ?", time.Now().Add(-r.opts.Retention)).Delete(&Event{})
+	if res.Error != nil {
+		zlog.Warn().Err(res.Error).Msg("outbox清理已发布事件失败")
+		return
+	}
+	if res.RowsAffected > 0 {
+		zlog.Info().Int64("rows", res.RowsAffected).Msg("outbox清理已发布事件")
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,106 @@
+package resp
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Code 业务状态码，实现了 error 接口，可以直接作为错误返回，或使用 fmt.Errorf("...: %w", resp.DBErr) 包装后
+// 通过 errors.Is 判断
+type Code string
+
+const (
+	OK                 Code = "OK"
+	InvalidErr         Code = "InvalidParamsErr"
+	UnauthorizedErr    Code = "UnauthorizedErr"
+	ForbiddenErr       Code = "ForbiddenErr"
+	NotFoundErr        Code = "NotFoundErr"
+	ConflictErr        Code = "ConflictErr"
+	PayloadTooLargeErr Code = "PayloadTooLargeErr"
+	TooManyRequestsErr Code = "TooManyRequestsErr"
+	DBErr              Code = "DBErr"
+	ServiceErr         Code = "ServiceErr"
+	InternalErr        Code = "InternalErr"
+	UnavailableErr     Code = "ServiceUnavailableErr"
+	TimeoutErr         Code = "TimeoutErr"
+)
+
+type codeInfo struct {
+	status int
+	msgs   map[string]string // 语言 -> 提示信息
+}
+
+var (
+	codes = map[Code]codeInfo{
+		OK:                 {http.StatusOK, map[string]string{LangZh: "OK", LangEn: "OK"}},
+		InvalidErr:         {http.StatusBadRequest, map[string]string{LangZh: "参数异常", LangEn: "Invalid parameters"}},
+		UnauthorizedErr:    {http.StatusUnauthorized, map[string]string{LangZh: "未授权", LangEn: "Unauthorized"}},
+		ForbiddenErr:       {http.StatusForbidden, map[string]string{LangZh: "禁止访问", LangEn: "Forbidden"}},
+		NotFoundErr:        {http.StatusNotFound, map[string]string{LangZh: "资源不存在", LangEn: "Not found"}},
+		ConflictErr:        {http.StatusConflict, map[string]string{LangZh: "请求冲突", LangEn: "Conflict"}},
+		PayloadTooLargeErr: {http.StatusRequestEntityTooLarge, map[string]string{LangZh: "请求体过大", LangEn: "Payload too large"}},
+		TooManyRequestsErr: {http.StatusTooManyRequests, map[string]string{LangZh: "请求过于频繁，请稍后再试！", LangEn: "Too many requests, please try again later"}},
+		DBErr:              {http.StatusInternalServerError, map[string]string{LangZh: "数据库异常", LangEn: "Database error"}},
+		ServiceErr:         {http.StatusInternalServerError, map[string]string{LangZh: "业务服务异常", LangEn: "Service error"}},
+		InternalErr:        {http.StatusInternalServerError, map[string]string{LangZh: "服务内部错误", LangEn: "Internal server error"}},
+		UnavailableErr:     {http.StatusServiceUnavailable, map[string]string{LangZh: "服务暂不可用", LangEn: "Service unavailable"}},
+		TimeoutErr:         {http.StatusGatewayTimeout, map[string]string{LangZh: "请求处理超时", LangEn: "Request timeout"}},
+	}
+	mu sync.RWMutex // 保护codes
+)
+
+// Register 注册状态码，status 为对应的 HTTP 状态码，msgs 为各语言的提示信息（如 {"zh": "余额不足", "en": "Insufficient balance"}），
+// 重复注册会覆盖，一般在 init 中调用
+func Register(code Code, status int, msgs map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	codes[code] = codeInfo{status: status, msgs: msgs}
+}
+
+func (c Code) Error() string {
+	return string(c)
+}
+
+// Status 状态码对应的 HTTP 状态码，未注册的状态码返回 500
+func (c Code) Status() int {
+	mu.RLock()
+	defer mu.RUnlock()
+	info, ok := codes[c]
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	return info.status
+}
+
+// Text 状态码在指定语言下的提示信息，没有该语言时使用 DefaultLang
+func (c Code) Text(lang string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	info, ok := codes[c]
+	if !ok {
+		return codes[InternalErr].msgs[DefaultLang]
+	}
+	if msg, ok := info.msgs[lang]; ok {
+		return msg
+	}
+	return info.msgs[DefaultLang]
+}
+
+// Output 创建使用该状态码的响应，提示信息为 DefaultLang 的文本，通过 Json 输出时会按请求的语言重新选择
+func (c Code) Output() *Output {
+	return &Output{
+		Code: c,
+		Msg:  c.Text(DefaultLang),
+		Data: nil,
+	}
+}
+
+// WithMsg 创建带自定义提示信息的错误
+func (c Code) WithMsg(msg string) *Error {
+	return &Error{Code: c, Msg: msg}
+}
+
+// Wrap 使用该状态码包装底层错误，底层错误只用于日志和 errors.Is 判断，不会返回给客户端
+func (c Code) Wrap(err error) *Error {
+	return &Error{Code: c, Err: err}
+}
@@ -0,0 +1,99 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// Error 带状态码的错误，Msg 不为空时替代状态码默认的提示信息返回给客户端
+type Error struct {
+	Code Code
+	Msg  string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	msg := string(e.Code)
+	if e.Msg != "" {
+		msg += ": " + e.Msg
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap 同时支持 errors.Is(err, resp.DBErr) 和 errors.Is(err, 底层错误)
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Code}
+	}
+	return []error{e.Code, e.Err}
+}
+
+// Matcher 自定义错误到状态码的映射，无法识别时返回 false
+type Matcher func(err error) (Code, bool)
+
+var (
+	matchers  []Matcher
+	matcherMu sync.RWMutex // 保护matchers
+)
+
+func init() {
+	RegisterError(context.DeadlineExceeded, TimeoutErr)
+	// 读取请求体超出 http.MaxBytesReader 的限制
+	RegisterMatcher(func(err error) (Code, bool) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return PayloadTooLargeErr, true
+		}
+		return "", false
+	})
+}
+
+// RegisterError 注册第三方错误到状态码的映射，使用 errors.Is 判断，如 RegisterError(gorm.ErrRecordNotFound, resp.NotFoundErr)
+func RegisterError(target error, code Code) {
+	RegisterMatcher(func(err error) (Code, bool) {
+		if errors.Is(err, target) {
+			return code, true
+		}
+		return "", false
+	})
+}
+
+// RegisterMatcher 注册自定义错误映射，适用于需要 errors.As 判断的错误类型，先注册的优先
+func RegisterMatcher(m Matcher) {
+	matcherMu.Lock()
+	defer matcherMu.Unlock()
+	matchers = append(matchers, m)
+}
+
+// FromError 将错误转换为响应：
+// *Error 使用其状态码和提示信息；Code 使用其默认提示信息；其次按注册的映射查找；都无法识别时返回 InternalErr
+func FromError(err error) *Output {
+	if err == nil {
+		return OK.Output()
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		o := e.Code.Output()
+		if e.Msg != "" {
+			o.WithMsg(e.Msg)
+		}
+		return o
+	}
+	var code Code
+	if errors.As(err, &code) {
+		return code.Output()
+	}
+	matcherMu.RLock()
+	defer matcherMu.RUnlock()
+	for _, m := range matchers {
+		if code, ok := m(err); ok {
+			return code.Output()
+		}
+	}
+	return InternalErr.Output()
+}
@@ -0,0 +1,79 @@
+package resp
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	LangZh = "zh"
+	LangEn = "en"
+)
+
+// DefaultLang 请求未指定语言或指定的语言不支持时使用的语言
+var DefaultLang = LangZh
+
+// Lang 按 Accept-Language 的优先级选择支持的语言，如 "en-US,en;q=0.9,zh;q=0.8" 返回 "en"
+func Lang(c *gin.Context) string {
+	header := c.GetHeader("Accept-Language")
+	if header == "" {
+		return DefaultLang
+	}
+	type tag struct {
+		lang string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		t := tag{q: 1}
+		name, params, _ := strings.Cut(part, ";")
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(v, 64); err == nil {
+				t.q = q
+			}
+		}
+		// 只取主语言，zh-CN、zh-Hans 都按 zh 处理
+		primary, _, _ := strings.Cut(strings.TrimSpace(name), "-")
+		t.lang = strings.ToLower(primary)
+		tags = append(tags, t)
+	}
+	slices.SortStableFunc(tags, func(a, b tag) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		default:
+			return 0
+		}
+	})
+	supported := languages()
+	for _, t := range tags {
+		if t.q > 0 && slices.Contains(supported, t.lang) {
+			return t.lang
+		}
+	}
+	return DefaultLang
+}
+
+// languages 已注册的提示信息中出现过的语言
+func languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	var langs []string
+	for _, info := range codes {
+		for lang := range info.msgs {
+			if !slices.Contains(langs, lang) {
+				langs = append(langs, lang)
+			}
+		}
+	}
+	return langs
+}
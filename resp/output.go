@@ -0,0 +1,63 @@
+package resp
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Output 统一的 JSON 响应结构
+type Output struct {
+	Code Code   `json:"code"`
+	Msg  string `json:"msg"`
+	Data any    `json:"data"`
+
+	customMsg bool // 使用了自定义提示信息，输出时不再按语言替换
+}
+
+func (o *Output) WithData(data any) *Output {
+	o.Data = data
+	return o
+}
+
+func (o *Output) WithMsg(msg string) *Output {
+	o.Msg = msg
+	o.customMsg = true
+	return o
+}
+
+// Status 响应对应的 HTTP 状态码
+func (o *Output) Status() int {
+	return o.Code.Status()
+}
+
+// Json 按请求的语言输出响应
+func (o *Output) Json(c *gin.Context) {
+	o.localize(c)
+	c.JSON(o.Status(), o)
+}
+
+// Abort 按请求的语言输出响应，并中止后续的处理函数，用于中间件
+func (o *Output) Abort(c *gin.Context) {
+	o.localize(c)
+	c.AbortWithStatusJSON(o.Status(), o)
+}
+
+func (o *Output) localize(c *gin.Context) {
+	if !o.customMsg {
+		o.Msg = o.Code.Text(Lang(c))
+	}
+}
+
+// Success 输出成功响应
+func Success(c *gin.Context, data any) {
+	OK.Output().WithData(data).Json(c)
+}
+
+// Fail 按错误输出失败响应，错误到状态码的映射见 FromError
+func Fail(c *gin.Context, err error) {
+	FromError(err).Json(c)
+}
+
+// AbortWithError 按错误输出失败响应并中止后续的处理函数
+func AbortWithError(c *gin.Context, err error) {
+	FromError(err).Abort(c)
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/chenparty/gog/health"
+	"github.com/chenparty/gog/zlog"
+)
+
+type closer struct {
+	name string
+	fn   func()
+}
+
+type Options struct {
+	ShutdownTimeout   time.Duration // 优雅关闭的最长时间，包含等待请求处理完成和关闭客户端，默认 30 秒
+	PreStopDelay      time.Duration // 收到信号后先标记为未就绪，等待负载均衡摘除流量后再关闭，默认 0
+	ReadHeaderTimeout time.Duration // 默认 10 秒
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration // 默认 120 秒
+
+	Signals []os.Signal // 触发优雅关闭的信号，默认 SIGINT、SIGTERM
+
+	closers []closer
+}
+
+type Option func(*Options)
+
+// Server 基于 http.Server 的 HTTP 服务，支持信号处理和优雅关闭
+type Server struct {
+	srv  *http.Server
+	opts Options
+}
+
+// New 创建HTTP服务，handler 一般为 *gin.Engine
+func New(addr string, handler http.Handler, options ...Option) *Server {
+	opts := Options{
+		ShutdownTimeout:   30 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		Signals:           []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&opts)
+		}
+	}
+	return &Server{
+		srv: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: opts.ReadHeaderTimeout,
+			ReadTimeout:       opts.ReadTimeout,
+			WriteTimeout:      opts.WriteTimeout,
+			IdleTimeout:       opts.IdleTimeout,
+		},
+		opts: opts,
+	}
+}
+
+// Run 创建HTTP服务并运行，直到收到退出信号后优雅关闭
+func Run(addr string, handler http.Handler, options ...Option) error {
+	return New(addr, handler, options...).Run()
+}
+
+// WithShutdownTimeout 设置优雅关闭的最长时间
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		if timeout > 0 {
+			options.ShutdownTimeout = timeout
+		}
+	}
+}
+
+// WithPreStopDelay 设置收到信号后、停止接收请求前的等待时间，K8s 环境下用于等待 Endpoint 摘除
+func WithPreStopDelay(delay time.Duration) Option {
+	return func(options *Options) {
+		options.PreStopDelay = delay
+	}
+}
+
+// WithTimeouts 设置 http.Server 的读写超时，为 0 时保持默认值
+func WithTimeouts(readHeader, read, write, idle time.Duration) Option {
+	return func(options *Options) {
+		if readHeader > 0 {
+			options.ReadHeaderTimeout = readHeader
+		}
+		if read > 0 {
+			options.ReadTimeout = read
+		}
+		if write > 0 {
+			options.WriteTimeout = write
+		}
+		if idle > 0 {
+			options.IdleTimeout = idle
+		}
+	}
+}
+
+// WithSignals 设置触发优雅关闭的信号
+func WithSignals(signals ...os.Signal) Option {
+	return func(options *Options) {
+		if len(signals) > 0 {
+			options.Signals = signals
+		}
+	}
+}
+
+// WithCloser 注册HTTP服务停止后需要关闭的客户端，按启动顺序注册，关闭时逆序执行，
+// 如 WithCloser("mysql", mysqlcli.Close), WithCloser("mqtt", mqttcli.Close)
+func WithCloser(name string, fn func()) Option {
+	return func(options *Options) {
+		if fn != nil {
+			options.closers = append(options.closers, closer{name: name, fn: fn})
+		}
+	}
+}
+
+// Addr 监听地址
+func (s *Server) Addr() string {
+	return s.srv.Addr
+}
+
+// ListenAndServe 开始监听并阻塞，服务被 Shutdown 时返回 nil
+func (s *Server) ListenAndServe() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		zlog.Error().Err(err).Str("addr", s.srv.Addr).Msg("HTTP服务监听失败")
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve 使用已创建的 listener 提供服务并阻塞，服务被 Shutdown 时返回 nil
+func (s *Server) Serve(ln net.Listener) error {
+	zlog.Info().Str("addr", ln.Addr().String()).Msg("HTTP服务启动")
+	err := s.srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Run 启动服务，收到退出信号或服务异常退出后，在 ShutdownTimeout 内完成优雅关闭
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), s.opts.Signals...)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		if err != nil {
+			zlog.Error().Err(err).Msg("HTTP服务异常退出")
+		}
+	case <-ctx.Done():
+		zlog.Info().Msg("收到退出信号，开始优雅关闭")
+	}
+	// 再次收到信号时使用默认行为，直接退出进程
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+	defer cancel()
+	if e := s.Shutdown(shutdownCtx); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// Shutdown 标记服务未就绪，停止接收新请求并等待处理中的请求完成，然后逆序关闭注册的客户端
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	health.SetReady(false)
+	if s.opts.PreStopDelay > 0 {
+		zlog.Info().Dur("delay", s.opts.PreStopDelay).Msg("等待负载均衡摘除流量")
+		select {
+		case <-time.After(s.opts.PreStopDelay):
+		case <-ctx.Done():
+		}
+	}
+	err = s.srv.Shutdown(ctx)
+	if err != nil {
+		zlog.Error().Err(err).Msg("HTTP服务未能在超时时间内处理完请求")
+	} else {
+		zlog.Info().Msg("HTTP服务已停止")
+	}
+	closeAll(ctx, s.opts.closers)
+	return
+}
+
+// closeAll 逆序关闭客户端，超时后不再等待未完成的关闭操作
+func closeAll(ctx context.Context, closers []closer) {
+	for i := len(closers) - 1; i >= 0; i-- {
+		c := closers[i]
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer func() {
+				if r := recover(); r != nil {
+					zlog.Error().Str("name", c.name).Any("panic", r).Msg("关闭客户端时发生panic")
+				}
+			}()
+			c.fn()
+		}()
+		select {
+		case <-done:
+			zlog.Info().Str("name", c.name).Msg("客户端已关闭")
+		case <-ctx.Done():
+			zlog.Warn().Str("name", c.name).Msg("关闭客户端超时")
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ginplugin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/chenparty/gog/resp"
+	"github.com/chenparty/gog/zlog"
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimit 限制请求体大小，Content-Length 超出 limit 时直接返回 413；
+// 未声明长度（如 chunked）的请求在处理函数执行前最多读取 limit+1 字节，超出时返回 413，未超出时放回请求体
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			zlog.Warn().Ctx(c.Request.Context()).Int64("content_length", c.Request.ContentLength).Int64("limit", limit).Msg("请求体过大")
+			resp.PayloadTooLargeErr.Output().Abort(c)
+			return
+		}
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		body := c.Request.Body
+		if c.Request.ContentLength < 0 {
+			// 处理函数读取到 *http.MaxBytesError 时往往自行返回 400，因此在处理函数之前判断
+			b, err := io.ReadAll(io.LimitReader(body, limit+1))
+			if int64(len(b)) > limit {
+				zlog.Warn().Ctx(c.Request.Context()).Int64("limit", limit).Msg("请求体过大")
+				resp.PayloadTooLargeErr.Output().Abort(c)
+				return
+			}
+			if err == nil {
+				body = io.NopCloser(bytes.NewReader(b))
+			} else {
+				// 读取失败时保留已读取的部分，处理函数继续读取时得到同样的错误
+				body = &prefixReadCloser{Reader: io.MultiReader(bytes.NewReader(b), body), Closer: body}
+			}
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, body, limit)
+		c.Next()
+	}
+}
@@ -0,0 +1,110 @@
+package ginplugin
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chenparty/gog/resp"
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	AllowOrigins     []string      // 允许的来源，支持 "*" 和 "https://*.example.com" 形式的子域名通配
+	AllowMethods     []string      // 允许的请求方法，为空时使用 GET、POST、PUT、PATCH、DELETE、HEAD、OPTIONS
+	AllowHeaders     []string      // 允许的请求头，为空时使用常用请求头，包含 "*" 时允许预检请求中的所有请求头
+	ExposeHeaders    []string      // 允许浏览器读取的响应头，为空时使用 HeaderRequestID
+	AllowCredentials bool          // 是否允许携带 Cookie 等凭证，允许时不会返回 "*"，而是返回请求的来源
+	MaxAge           time.Duration // 预检结果的缓存时间，为 0 时不设置
+}
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions}
+	defaultCORSHeaders = []string{"Origin", "Content-Type", "Accept", "Accept-Language", "Authorization", HeaderRequestID, HeaderRequestTimeout, HeaderIdempotencyKey}
+)
+
+// CORS 跨域中间件，来源不被允许的预检请求返回 403，普通请求不设置跨域响应头，由浏览器拦截
+func CORS(config CORSConfig) gin.HandlerFunc {
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = defaultCORSMethods
+	}
+	if len(config.AllowHeaders) == 0 {
+		config.AllowHeaders = defaultCORSHeaders
+	}
+	if len(config.ExposeHeaders) == 0 {
+		config.ExposeHeaders = []string{HeaderRequestID}
+	}
+	allowAll := slices.Contains(config.AllowOrigins, "*")
+	allowAllHeaders := slices.Contains(config.AllowHeaders, "*")
+	methods := strings.Join(config.AllowMethods, ",")
+	headers := strings.Join(config.AllowHeaders, ",")
+	expose := strings.Join(config.ExposeHeaders, ",")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !allowAll && !originAllowed(config.AllowOrigins, origin) {
+			if preflight {
+				resp.ForbiddenErr.Output().Abort(c)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		if allowAll && !config.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", expose)
+			c.Next()
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", methods)
+		if allowAllHeaders {
+			h.Set("Access-Control-Allow-Headers", c.GetHeader("Access-Control-Request-Headers"))
+		} else {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+		if config.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func originAllowed(allowOrigins []string, origin string) bool {
+	for _, allow := range allowOrigins {
+		if strings.EqualFold(allow, origin) {
+			return true
+		}
+		// 子域名通配，如 https://*.example.com
+		scheme, pattern, ok := strings.Cut(allow, "*.")
+		if !ok {
+			continue
+		}
+		rest, ok := strings.CutPrefix(origin, scheme)
+		if ok && strings.HasSuffix(rest, "."+pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package ginplugin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/chenparty/gog/health"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterHealthRoutes 注册 /livez、/healthz、/readyz，timeout 为单个依赖检查的超时时间
+func RegisterHealthRoutes(r gin.IRoutes, timeout time.Duration) {
+	r.GET("/livez", LivenessHandler())
+	r.GET("/healthz", HealthHandler(timeout))
+	r.GET("/readyz", ReadinessHandler(timeout))
+}
+
+// LivenessHandler 存活检查，进程能处理请求即返回 200，不检查依赖，避免依赖故障导致容器被反复重启
+func LivenessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+	}
+}
+
+// HealthHandler 检查所有已注册的依赖，全部可用时返回 200，否则返回 503，响应中包含每个依赖的检查详情
+func HealthHandler(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := health.Check(c.Request.Context(), timeout)
+		if !report.Up() {
+			c.JSON(http.StatusServiceUnavailable, report)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// ReadinessHandler 就绪检查，服务未就绪（启动中、优雅关闭中）或依赖不可用时返回 503
+func ReadinessHandler(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !health.IsReady() {
+			c.JSON(http.StatusServiceUnavailable, health.Report{Status: health.StatusDown, Checks: map[string]health.Result{}})
+			return
+		}
+		report := health.Check(c.Request.Context(), timeout)
+		if !report.Up() {
+			c.JSON(http.StatusServiceUnavailable, report)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
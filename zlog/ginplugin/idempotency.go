@@ -0,0 +1,260 @@
+package ginplugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/chenparty/gog/client/rediscli"
+	"github.com/chenparty/gog/resp"
+	"github.com/chenparty/gog/zlog"
+	"github.com/gin-gonic/gin"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// HeaderIdempotencyKey 客户端传递的幂等键
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed 响应为重放的首次响应时设置为 true
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+const (
+	IdempotencyKeyMissingErr resp.Code = "IdempotencyKeyMissingErr"
+	IdempotencyInProgressErr resp.Code = "IdempotencyInProgressErr"
+	IdempotencyKeyReusedErr  resp.Code = "IdempotencyKeyReusedErr"
+)
+
+func init() {
+	resp.Register(IdempotencyKeyMissingErr, http.StatusBadRequest, map[string]string{
+		resp.LangZh: "缺少 Idempotency-Key 请求头",
+		resp.LangEn: "Missing Idempotency-Key header",
+	})
+	resp.Register(IdempotencyInProgressErr, http.StatusConflict, map[string]string{
+		resp.LangZh: "相同幂等键的请求正在处理中，请稍后重试",
+		resp.LangEn: "A request with the same idempotency key is in progress",
+	})
+	resp.Register(IdempotencyKeyReusedErr, http.StatusUnprocessableEntity, map[string]string{
+		resp.LangZh: "幂等键已被用于不同的请求",
+		resp.LangEn: "Idempotency key was reused with a different request",
+	})
+}
+
+// IdempotencyConfig 幂等配置
+type IdempotencyConfig struct {
+	KeyPrefix       string        // redis key 前缀，默认 "gog:idempotency:"
+	TTL             time.Duration // 首次响应的保存时间，默认 24 小时
+	LockTTL         time.Duration // 处理中锁的过期时间，应大于请求的超时时间，默认 1 分钟
+	Methods         []string      // 需要幂等处理的请求方法，默认 POST、PATCH
+	Required        bool          // 请求未携带 Idempotency-Key 时是否拒绝
+	MaxResponseSize int           // 最多保存的响应体字节数，超出时不保存，默认 1 MB
+	MaxRequestSize  int           // 计算请求摘要时最多读取的请求体字节数，超出时返回 413，默认 1 MB
+}
+
+// idempotentRecord 保存到 redis 的首次响应
+type idempotentRecord struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// Idempotency 幂等中间件，依赖 rediscli。
+// 首次请求的响应（状态码、响应头、响应体）保存到 redis，相同 Idempotency-Key 的重复请求直接重放；
+// 首次请求处理中时，重复请求返回 409；Idempotency-Key 相同但请求方法、路径或请求体不同时返回 422。
+// 5xx 响应不保存，客户端可以使用相同的 Idempotency-Key 重试
+func Idempotency(config IdempotencyConfig) gin.HandlerFunc {
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "gog:idempotency:"
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.LockTTL <= 0 {
+		config.LockTTL = time.Minute
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if config.MaxResponseSize <= 0 {
+		config.MaxResponseSize = 1 << 20
+	}
+	if config.MaxRequestSize <= 0 {
+		config.MaxRequestSize = 1 << 20
+	}
+	return func(c *gin.Context) {
+		if !slices.Contains(config.Methods, c.Request.Method) {
+			c.Next()
+			return
+		}
+		key := c.GetHeader(HeaderIdempotencyKey)
+		if key == "" {
+			if config.Required {
+				IdempotencyKeyMissingErr.Output().Abort(c)
+				return
+			}
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		fingerprint, tooLarge, err := requestFingerprint(c, config.MaxRequestSize)
+		if err != nil {
+			zlog.Error().Ctx(ctx).Err(err).Msg("Idempotency 读取请求体失败")
+			resp.AbortWithError(c, err)
+			return
+		}
+		if tooLarge {
+			zlog.Warn().Ctx(ctx).Int("limit", config.MaxRequestSize).Msg("Idempotency 请求体过大")
+			resp.PayloadTooLargeErr.Output().Abort(c)
+			return
+		}
+		storeKey := config.KeyPrefix + key
+		lockKey := storeKey + ":lock"
+
+		// 已有首次响应，校验请求一致后重放
+		if replayStored(c, storeKey, fingerprint) {
+			return
+		}
+
+		// 加锁，避免并发的重复请求同时处理；锁的值为本次请求的随机令牌，释放时只删除自己持有的锁
+		token := ulid.Make().String()
+		locked, err := rediscli.SetNX(ctx, lockKey, token, config.LockTTL)
+		if err != nil {
+			zlog.Error().Ctx(ctx).Err(err).Str("key", lockKey).Msg("Idempotency 加锁失败")
+			resp.UnavailableErr.Output().Abort(c)
+			return
+		}
+		if !locked {
+			c.Header("Retry-After", "1")
+			IdempotencyInProgressErr.Output().Abort(c)
+			return
+		}
+		defer func() {
+			// 使用独立的 context，避免请求超时导致锁无法释放；锁已过期并被其它请求获取时不删除
+			if _, err := rediscli.DelIfEqual(context.WithoutCancel(ctx), lockKey, token); err != nil {
+				zlog.Error().Ctx(ctx).Err(err).Str("key", lockKey).Msg("Idempotency 释放锁失败")
+			}
+		}()
+		// 查询首次响应之后、加锁之前，首次请求可能已处理完成并释放锁，加锁后需要再次检查
+		if replayStored(c, storeKey, fingerprint) {
+			return
+		}
+
+		writer := &recordResponseWriter{ResponseWriter: c.Writer, limit: config.MaxResponseSize}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError || writer.exceeded {
+			return
+		}
+		header := writer.Header().Clone()
+		for _, h := range []string{"Content-Length", "Date", "Set-Cookie", HeaderRequestID} {
+			header.Del(h)
+		}
+		data, err := json.Marshal(idempotentRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      header,
+			Body:        writer.body.Bytes(),
+		})
+		if err == nil {
+			err = rediscli.SetEx(context.WithoutCancel(ctx), storeKey, data, config.TTL)
+		}
+		if err != nil {
+			zlog.Error().Ctx(ctx).Err(err).Str("key", storeKey).Msg("Idempotency 保存首次响应失败")
+		}
+	}
+}
+
+// replayStored 已有首次响应时校验请求一致后重放，返回请求是否已处理
+func replayStored(c *gin.Context, storeKey, fingerprint string) (handled bool) {
+	ctx := c.Request.Context()
+	data, isNotExist, err := rediscli.Get[[]byte](ctx, storeKey)
+	if err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("key", storeKey).Msg("Idempotency 查询首次响应失败")
+		resp.UnavailableErr.Output().Abort(c)
+		return true
+	}
+	if isNotExist {
+		return false
+	}
+	var record idempotentRecord
+	if err = json.Unmarshal(data, &record); err != nil {
+		zlog.Error().Ctx(ctx).Err(err).Str("key", storeKey).Msg("Idempotency 解析首次响应失败")
+		resp.InternalErr.Output().Abort(c)
+		return true
+	}
+	if record.Fingerprint != fingerprint {
+		IdempotencyKeyReusedErr.Output().Abort(c)
+		return true
+	}
+	replay(c, record)
+	return true
+}
+
+// requestFingerprint 请求方法、路径和请求体的摘要，用于识别重复使用的幂等键；请求体超出 limit 时返回 tooLarge
+func requestFingerprint(c *gin.Context, limit int) (fingerprint string, tooLarge bool, err error) {
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(limit)+1))
+		if err != nil {
+			return "", false, err
+		}
+		if len(body) > limit {
+			return "", true, nil
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), false, nil
+}
+
+func replay(c *gin.Context, record idempotentRecord) {
+	h := c.Writer.Header()
+	for k, v := range record.Header {
+		h[k] = v
+	}
+	h.Set(HeaderIdempotentReplayed, "true")
+	c.Status(record.Status)
+	_, _ = c.Writer.Write(record.Body)
+	c.Abort()
+}
+
+// recordResponseWriter 记录完整的响应体，超出 limit 后不再记录
+type recordResponseWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (w *recordResponseWriter) Write(p []byte) (int, error) {
+	w.record(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordResponseWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *recordResponseWriter) record(p []byte) {
+	if w.exceeded {
+		return
+	}
+	if w.body.Len()+len(p) > w.limit {
+		w.exceeded = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(p)
+}
@@ -2,45 +2,82 @@ package ginplugin
 
 import (
 	"fmt"
+	"github.com/chenparty/gog/resp"
 	"github.com/gin-gonic/gin"
 	"net"
 	"strings"
+	"sync/atomic"
 )
 
 // IPWhitelist 创建IP白名单中间件
 func IPWhitelist(whitelist []string) gin.HandlerFunc {
-	// 白名单为空时，允许所有IP访问
-	if len(whitelist) == 0 {
-		return func(c *gin.Context) {
-			c.Next()
-		}
+	f, err := NewIPWhitelistFilter(whitelist)
+	if err != nil {
+		// 解析失败直接panic（建议在服务启动时检查）
+		panic(err)
+	}
+	return f.Handler()
+}
+
+// IPWhitelistFilter 可在运行时更新的IP白名单，如由配置中心推送新的白名单
+type IPWhitelistFilter struct {
+	ipNets atomic.Pointer[[]*net.IPNet]
+}
+
+// NewIPWhitelistFilter 创建IP白名单，白名单为空时允许所有IP访问
+func NewIPWhitelistFilter(whitelist []string) (f *IPWhitelistFilter, err error) {
+	f = &IPWhitelistFilter{}
+	if err = f.Update(whitelist); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Update 替换白名单，任一条目解析失败时返回错误，白名单保持不变
+func (f *IPWhitelistFilter) Update(whitelist []string) error {
+	ipNets, err := parseIPNets(whitelist)
+	if err != nil {
+		return err
 	}
-	// 预处理白名单：解析为IPNet对象
+	f.ipNets.Store(&ipNets)
+	return nil
+}
+
+// parseIPNets 预处理白名单：解析为IPNet对象
+func parseIPNets(whitelist []string) ([]*net.IPNet, error) {
 	ipNets := make([]*net.IPNet, 0, len(whitelist))
 	for _, item := range whitelist {
-		// 处理单个IP（如 "192.168.1.1"）
+		item = strings.TrimSpace(item)
 		if !strings.Contains(item, "/") {
-			item += "/32" // 单个IPv4转换为CIDR
-		}
-
-		// 处理IPv6单个地址（如 "::1"）
-		if strings.Count(item, ":") >= 2 && !strings.Contains(item, "/") {
-			item += "/128"
+			// 处理单个IP（如 "192.168.1.1"、"::1"）
+			if ip := net.ParseIP(item); ip != nil && ip.To4() == nil {
+				item += "/128"
+			} else {
+				item += "/32"
+			}
 		}
-
 		_, ipNet, err := net.ParseCIDR(item)
 		if err != nil {
-			// 解析失败直接panic（建议在服务启动时检查）
-			panic(fmt.Sprintf("invalid CIDR %s: %v", item, err))
+			return nil, fmt.Errorf("invalid CIDR %s: %w", item, err)
 		}
 		ipNets = append(ipNets, ipNet)
 	}
+	return ipNets, nil
+}
 
+// Handler 白名单中间件
+func (f *IPWhitelistFilter) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ipNets := *f.ipNets.Load()
+		// 白名单为空时，允许所有IP访问
+		if len(ipNets) == 0 {
+			c.Next()
+			return
+		}
 		// 获取客户端真实IP
 		clientIP := net.ParseIP(c.ClientIP())
 		if clientIP == nil {
-			c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden"})
+			resp.ForbiddenErr.Output().Abort(c)
 			return
 		}
 
@@ -54,7 +91,7 @@ func IPWhitelist(whitelist []string) gin.HandlerFunc {
 		}
 
 		if !allowed {
-			c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden"})
+			resp.ForbiddenErr.Output().Abort(c)
 			return
 		}
 
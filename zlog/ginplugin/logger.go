@@ -1,16 +1,74 @@
 package ginplugin
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
 	"github.com/chenparty/gog/zlog"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+// defaultMaxLogBodySize 默认最多记录的请求体、响应体字节数
+const defaultMaxLogBodySize = 2000
+
+// DefaultReqBodyMethods 默认记录请求体的请求方法
+var DefaultReqBodyMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// DefaultSkipRespContentTypes 默认不记录响应体的 Content-Type 前缀（二进制、流式响应）
+var DefaultSkipRespContentTypes = []string{
+	"text/event-stream",
+	"application/octet-stream",
+	"application/zip",
+	"application/pdf",
+	"image/",
+	"audio/",
+	"video/",
+}
+
+// LoggerConfig GinLogger 配置
+type LoggerConfig struct {
+	AutoCopyRequestBody bool     // 是否记录请求体
+	ReqBodyMethods      []string // 记录请求体的请求方法，为 nil 时使用 DefaultReqBodyMethods
+	MaxReqBodySize      int      // 最多记录的请求体字节数，超出部分截断，默认 2000
+
+	MaxRespBodySize      int      // 最多记录的响应体字节数，超出部分截断，默认 2000
+	SkipRespContentTypes []string // 不记录响应体的 Content-Type 前缀，为 nil 时使用 DefaultSkipRespContentTypes
+	SkipRespPaths        []string // 不记录响应体的路由，可以是路由模板（如 /v1/file/:id）或请求 path
+}
+
 // GinLogger 日志
 func GinLogger(autoCopyRequestBody bool, maxLogBodySize int) gin.HandlerFunc {
+	return GinLoggerWithConfig(LoggerConfig{
+		AutoCopyRequestBody: autoCopyRequestBody,
+		MaxRespBodySize:     maxLogBodySize,
+	})
+}
+
+// GinLoggerWithConfig 日志，响应体最多缓存 MaxRespBodySize 字节，不会因大文件下载或流式响应占用大量内存
+func GinLoggerWithConfig(config LoggerConfig) gin.HandlerFunc {
+	if config.ReqBodyMethods == nil {
+		config.ReqBodyMethods = DefaultReqBodyMethods
+	}
+	if config.MaxReqBodySize <= 0 {
+		config.MaxReqBodySize = defaultMaxLogBodySize
+	}
+	if config.MaxRespBodySize <= 0 {
+		config.MaxRespBodySize = defaultMaxLogBodySize
+	}
+	if config.SkipRespContentTypes == nil {
+		config.SkipRespContentTypes = DefaultSkipRespContentTypes
+	}
 	return func(c *gin.Context) {
 		//先打印请求头信息
 		path := c.Request.URL.Path
@@ -25,11 +83,9 @@ func GinLogger(autoCopyRequestBody bool, maxLogBodySize int) gin.HandlerFunc {
 			Str("client_ip", c.ClientIP()).
 			Str("user_agent", c.Request.UserAgent()).
 			Str("content_type", c.ContentType())
-		if autoCopyRequestBody && c.Request.Method == "POST" {
-			b, _ := c.Copy().GetRawData()
-			reqEvent.Str("req_body", string(b))
-			// 重置请求体
-			c.Request.Body = io.NopCloser(bytes.NewReader(b))
+		captured := false
+		if config.AutoCopyRequestBody && slices.Contains(config.ReqBodyMethods, c.Request.Method) {
+			captured = logRequestBody(c, reqEvent, config.MaxReqBodySize)
 		}
 		start := time.Now()
 		reqEvent.Msg("GinRequest")
@@ -37,7 +93,9 @@ func GinLogger(autoCopyRequestBody bool, maxLogBodySize int) gin.HandlerFunc {
 		// 创建自定义的 ResponseWriter
 		customWriter := &CustomResponseWriter{
 			ResponseWriter: c.Writer,
-			body:           bytes.NewBuffer([]byte{}),
+			limit:          config.MaxRespBodySize,
+			skipTypes:      config.SkipRespContentTypes,
+			skip:           slices.Contains(config.SkipRespPaths, c.FullPath()) || slices.Contains(config.SkipRespPaths, c.Request.URL.Path),
 		}
 		// 替换原始的 ResponseWriter
 		c.Writer = customWriter
@@ -53,8 +111,9 @@ func GinLogger(autoCopyRequestBody bool, maxLogBodySize int) gin.HandlerFunc {
 		} else {
 			respEvent = zlog.Info()
 		}
+		// 请求开始时已记录请求体的，不再重复记录
 		val, isExist := getRequestBody(c)
-		if isExist {
+		if isExist && !captured {
 			requestBody, ok := val.([]byte)
 			if ok {
 				respEvent = respEvent.Str("req_body", string(requestBody))
@@ -62,13 +121,18 @@ func GinLogger(autoCopyRequestBody bool, maxLogBodySize int) gin.HandlerFunc {
 				respEvent = respEvent.Any("req_body", val)
 			}
 		}
-		// 获取响应的字节内容,太大的也不打印
-		if maxLogBodySize <= 0 {
-			maxLogBodySize = 2000
+		// 响应体只记录捕获到的部分，超出上限时标记截断
+		if customWriter.body != nil && customWriter.body.Len() > 0 {
+			respEvent.Str("resp_body", customWriter.body.String())
+			if customWriter.truncated {
+				respEvent.Bool("resp_body_truncated", true)
+			}
 		}
-		if c.Writer.Size() > 0 && c.Writer.Size() < maxLogBodySize {
-			responseBody := customWriter.body
-			respEvent.Str("resp_body", string(responseBody.Bytes()))
+		if customWriter.flushed {
+			respEvent.Bool("streamed", true)
+		}
+		if customWriter.hijacked {
+			respEvent.Bool("hijacked", true)
 		}
 		// 打印
 		respEvent.Ctx(c.Request.Context()).
@@ -80,19 +144,158 @@ func GinLogger(autoCopyRequestBody bool, maxLogBodySize int) gin.HandlerFunc {
 	}
 }
 
-// CustomResponseWriter 自定义的 ResponseWriter
+// logRequestBody 最多读取 limit 字节请求体记录到日志，并把读取的部分放回请求体，不影响后续处理。
+// multipart 请求从读取的部分中解析字段名和文件信息，不记录文件内容；超出上限的字段和文件不记录
+func logRequestBody(c *gin.Context, event *zerolog.Event, limit int) (captured bool) {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return
+	}
+	contentType := c.ContentType()
+	body := c.Request.Body
+	b, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+	// 重置请求体，已读取的部分和未读取的部分拼接起来
+	c.Request.Body = &prefixReadCloser{Reader: io.MultiReader(bytes.NewReader(b), body), Closer: body}
+	if err != nil {
+		event.AnErr("req_body_err", err)
+		return
+	}
+	truncated := len(b) > limit
+	if truncated {
+		b = b[:limit]
+		event.Bool("req_body_truncated", true)
+	}
+	if contentType == gin.MIMEMultipartPOSTForm {
+		event.Dict("req_multipart", multipartPrefixDict(c.GetHeader("Content-Type"), b))
+		return true
+	}
+	switch {
+	case contentType == gin.MIMEJSON || strings.HasSuffix(contentType, "+json"):
+		// 完整的 JSON 保持结构化输出，截断后只能按字符串输出
+		if !truncated && json.Valid(b) {
+			event.RawJSON("req_body", b)
+		} else {
+			event.Str("req_body", string(b))
+		}
+	case contentType == gin.MIMEPOSTForm:
+		values, _ := url.ParseQuery(string(b))
+		form := make(map[string]any, len(values))
+		for k, v := range values {
+			if len(v) == 1 {
+				form[k] = v[0]
+			} else {
+				form[k] = v
+			}
+		}
+		event.Any("req_body", form)
+	case utf8.Valid(b):
+		event.Str("req_body", string(b))
+	default:
+		// 二进制内容不记录
+		event.Int("req_body_size", len(b))
+	}
+	return true
+}
+
+// multipartPrefixDict 从请求体的前缀中解析 multipart 的字段名和文件信息，超出前缀的部分不解析，
+// 文件内容完整包含在前缀中时才记录文件大小
+func multipartPrefixDict(contentType string, prefix []byte) *zerolog.Event {
+	fields := []string{}
+	files := zerolog.Arr()
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["boundary"] != "" {
+		mr := multipart.NewReader(bytes.NewReader(prefix), params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			if part.FileName() == "" {
+				fields = append(fields, part.FormName())
+				continue
+			}
+			file := zerolog.Dict().
+				Str("field", part.FormName()).
+				Str("filename", part.FileName()).
+				Str("content_type", part.Header.Get("Content-Type"))
+			if n, err := io.Copy(io.Discard, part); err == nil {
+				file.Int64("size", n)
+			}
+			files.Dict(file)
+		}
+	}
+	return zerolog.Dict().Strs("fields", fields).Array("files", files)
+}
+
+type prefixReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// CustomResponseWriter 自定义的 ResponseWriter，最多捕获 limit 字节的响应体用于日志
 type CustomResponseWriter struct {
 	gin.ResponseWriter
 	body *bytes.Buffer
+
+	limit     int
+	skipTypes []string
+	skip      bool // 不捕获响应体
+	checked   bool // 是否已根据 Content-Type 判断过
+	truncated bool // 响应体超出 limit 被截断
+	flushed   bool // 调用过 Flush（流式响应）
+	hijacked  bool // 连接被接管（如 websocket）
 }
 
 func (c *CustomResponseWriter) Write(p []byte) (n int, err error) {
 	// 捕获写入的内容
-	c.body.Write(p)
+	c.capture(p)
 	// 继续写入原始的 ResponseWriter
 	return c.ResponseWriter.Write(p)
 }
 
+func (c *CustomResponseWriter) WriteString(s string) (n int, err error) {
+	c.capture([]byte(s))
+	return c.ResponseWriter.WriteString(s)
+}
+
+// Flush 流式响应会多次调用 Flush，透传给原始的 ResponseWriter
+func (c *CustomResponseWriter) Flush() {
+	c.flushed = true
+	c.ResponseWriter.Flush()
+}
+
+// Hijack 连接被接管后不再捕获任何内容
+func (c *CustomResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	c.hijacked = true
+	c.skip = true
+	return c.ResponseWriter.Hijack()
+}
+
+func (c *CustomResponseWriter) capture(p []byte) {
+	if !c.checked {
+		// 第一次写入时响应头已确定，根据 Content-Type 判断是否需要捕获
+		c.checked = true
+		contentType := c.Header().Get("Content-Type")
+		for _, prefix := range c.skipTypes {
+			if strings.HasPrefix(contentType, prefix) {
+				c.skip = true
+				break
+			}
+		}
+	}
+	if c.skip || c.truncated {
+		return
+	}
+	if c.body == nil {
+		c.body = bytes.NewBuffer(make([]byte, 0, min(c.limit, 512)))
+	}
+	remain := c.limit - c.body.Len()
+	if len(p) > remain {
+		c.body.Write(p[:remain])
+		c.truncated = true
+		return
+	}
+	c.body.Write(p)
+}
+
 // RequestBodyKey 用于日志输出请求体，避免二次解包
 // 调用时可以在请求Handler里解析完参数后再defer SetRequestBody即可
 const RequestBodyKey = "RequestBodyKey"
@@ -0,0 +1,35 @@
+package ginplugin
+
+import (
+	"time"
+
+	"github.com/chenparty/gog/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute 未匹配到路由时使用的 route 标签值，避免把任意 path 写进指标造成标签爆炸
+const unmatchedRoute = "unmatched"
+
+// GinMetrics 记录请求数、耗时直方图以及处理中的请求数，按路由模板和状态码统计
+func GinMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		inFlight := metrics.HTTPInFlight(method, route)
+		inFlight.Inc()
+		start := time.Now()
+		defer func() {
+			inFlight.Dec()
+			metrics.ObserveHTTPRequest(method, route, c.Writer.Status(), time.Since(start))
+		}()
+		c.Next()
+	}
+}
+
+// MetricsHandler 输出 Prometheus 指标，一般挂载在 /metrics
+func MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(metrics.Handler())
+}
@@ -1,12 +1,13 @@
 package ginplugin
 
 import (
+	"github.com/chenparty/gog/resp"
 	"github.com/gin-gonic/gin"
 	"github.com/maypok86/otter"
 	"golang.org/x/time/rate"
-	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -30,20 +31,54 @@ func IPRateLimit(ipCacheCapacity int, timeWindow time.Duration, maxRequests int)
 	if ipCacheCapacity <= 0 {
 		ipCacheCapacity = defaultCacheCapacity
 	}
+	// 初始化请求限流信息缓存（只初始化一次）
+	cacheInitOnce.Do(func() {
+		requestInfoCache = newRequestInfoCache(ipCacheCapacity)
+	})
+	l := &IPRateLimiter{cache: requestInfoCache}
+	l.Update(timeWindow, maxRequests)
+	return l.Handler()
+}
+
+// IPRateLimiter 可在运行时调整阈值的 IP 限流器，如由配置中心推送新的时间窗口和最大请求数
+type IPRateLimiter struct {
+	cache       otter.Cache[string, *requestInfo]
+	timeWindow  atomic.Int64
+	maxRequests atomic.Int64
+}
+
+// NewIPRateLimiter 创建 IP 限流器，使用独立的缓存，参数小于等于 0 时使用默认值
+func NewIPRateLimiter(ipCacheCapacity int, timeWindow time.Duration, maxRequests int) *IPRateLimiter {
+	if ipCacheCapacity <= 0 {
+		ipCacheCapacity = defaultCacheCapacity
+	}
+	l := &IPRateLimiter{cache: newRequestInfoCache(ipCacheCapacity)}
+	l.Update(timeWindow, maxRequests)
+	return l
+}
+
+func newRequestInfoCache(capacity int) otter.Cache[string, *requestInfo] {
+	cache, err := otter.MustBuilder[string, *requestInfo](capacity).WithTTL(defaultCacheExpire).Build()
+	if err != nil {
+		panic(err)
+	}
+	return cache
+}
+
+// Update 修改时间窗口和最大请求数，对之后的请求立即生效，参数小于等于 0 时使用默认值
+func (l *IPRateLimiter) Update(timeWindow time.Duration, maxRequests int) {
 	if timeWindow <= 0 {
 		timeWindow = defaultTimeWindow
 	}
 	if maxRequests <= 0 {
 		maxRequests = defaultMaxRequests
 	}
-	// 初始化请求限流信息缓存（只初始化一次）
-	cacheInitOnce.Do(func() {
-		var err error
-		requestInfoCache, err = otter.MustBuilder[string, *requestInfo](ipCacheCapacity).WithTTL(defaultCacheExpire).Build()
-		if err != nil {
-			panic(err)
-		}
-	})
+	l.timeWindow.Store(int64(timeWindow))
+	l.maxRequests.Store(int64(maxRequests))
+}
+
+// Handler 限流中间件
+func (l *IPRateLimiter) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userAgent := c.Request.UserAgent()
 		// 如果是微服务内部调用放行
@@ -52,30 +87,27 @@ func IPRateLimit(ipCacheCapacity int, timeWindow time.Duration, maxRequests int)
 			return
 		}
 		ip := c.ClientIP()
-		info, ok := requestInfoCache.Get(ip)
+		info, ok := l.cache.Get(ip)
 		// 如果IP不存在，初始化并添加到缓存中，并放行
 		if !ok {
-			requestInfoCache.Set(ip, &requestInfo{LastAccessTime: time.Now(), RequestNum: 1})
+			l.cache.Set(ip, &requestInfo{LastAccessTime: time.Now(), RequestNum: 1})
 			c.Next()
 			return
 		}
 		// 如果超过时间窗口，重置请求计数，并放行
-		if time.Since(info.LastAccessTime) > timeWindow {
+		if time.Since(info.LastAccessTime) > time.Duration(l.timeWindow.Load()) {
 			info.RequestNum = 1
 			info.LastAccessTime = time.Now()
-			requestInfoCache.Set(ip, info)
+			l.cache.Set(ip, info)
 			c.Next()
 			return
 		}
 		// 如果在时间窗口内，增加请求计数
 		info.RequestNum++
 		// 如果请求计数超过限制，禁止访问
-		if info.RequestNum > maxRequests {
+		if int64(info.RequestNum) > l.maxRequests.Load() {
 			// 如果请求被限制，返回 429 状态码
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "请求过于频繁，请稍后再试！",
-			})
-			c.Abort()
+			resp.TooManyRequestsErr.Output().Abort(c)
 			return
 		}
 		// 更新最后访问时间
@@ -86,16 +118,32 @@ func IPRateLimit(ipCacheCapacity int, timeWindow time.Duration, maxRequests int)
 
 // RateLimit 全局限流器（令牌桶）-基于内存
 func RateLimit(time time.Duration, rps, burst int) gin.HandlerFunc {
-	// 创建一个速率限制器（令牌桶），每秒最大 `rps` 次请求，最多突发 `burst` 次请求
-	limiter := rate.NewLimiter(rate.Limit(rps), burst)
+	return NewRateLimiter(rps, burst).Handler()
+}
+
+// RateLimiter 可在运行时调整速率的全局限流器（令牌桶）
+type RateLimiter struct {
+	limiter *rate.Limiter
+}
+
+// NewRateLimiter 创建全局限流器，每秒最大 rps 次请求，最多突发 burst 次请求
+func NewRateLimiter(rps, burst int) *RateLimiter {
+	return &RateLimiter{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+}
+
+// SetRate 修改每秒请求数和突发请求数，对之后的请求立即生效
+func (l *RateLimiter) SetRate(rps, burst int) {
+	l.limiter.SetLimit(rate.Limit(rps))
+	l.limiter.SetBurst(burst)
+}
+
+// Handler 限流中间件
+func (l *RateLimiter) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 限制请求数量
-		if !limiter.Allow() {
+		if !l.limiter.Allow() {
 			// 如果请求被限制，返回 429 状态码
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "请求过于频繁，请稍后再试！",
-			})
-			c.Abort()
+			resp.TooManyRequestsErr.Output().Abort(c)
 			return
 		}
 	}
@@ -2,9 +2,9 @@ package ginplugin
 
 import (
 	"fmt"
+	"github.com/chenparty/gog/resp"
 	"github.com/chenparty/gog/zlog"
 	"github.com/gin-gonic/gin"
-	"net/http"
 	"runtime/debug"
 )
 
@@ -18,7 +18,7 @@ func Recovery(stack bool) gin.HandlerFunc {
 				} else {
 					zlog.Error().Ctx(c.Request.Context()).Msg(fmt.Sprint("Recovery from panic:", err))
 				}
-				c.AbortWithStatus(http.StatusInternalServerError)
+				resp.InternalErr.Output().Abort(c)
 			}
 		}()
 		c.Next()
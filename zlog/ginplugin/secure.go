@@ -0,0 +1,63 @@
+package ginplugin
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecureConfig 安全响应头配置，字段为空时不设置对应的响应头
+type SecureConfig struct {
+	HSTSMaxAge            time.Duration // Strict-Transport-Security 的 max-age，只在 HTTPS 下生效
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	ContentSecurityPolicy string // Content-Security-Policy
+	FrameOptions          string // X-Frame-Options，如 DENY、SAMEORIGIN
+	ContentTypeNosniff    bool   // X-Content-Type-Options: nosniff
+	ReferrerPolicy        string // Referrer-Policy
+}
+
+// DefaultSecureConfig 适用于 API 服务的默认安全响应头
+func DefaultSecureConfig() SecureConfig {
+	return SecureConfig{
+		HSTSMaxAge:            180 * 24 * time.Hour,
+		ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+		FrameOptions:          "DENY",
+		ContentTypeNosniff:    true,
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+	}
+}
+
+// SecureHeaders 设置安全响应头
+func SecureHeaders(config SecureConfig) gin.HandlerFunc {
+	var hsts string
+	if config.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(config.HSTSMaxAge.Seconds()), 10)
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if config.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		if hsts != "" {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		if config.ContentSecurityPolicy != "" {
+			h.Set("Content-Security-Policy", config.ContentSecurityPolicy)
+		}
+		if config.FrameOptions != "" {
+			h.Set("X-Frame-Options", config.FrameOptions)
+		}
+		if config.ContentTypeNosniff {
+			h.Set("X-Content-Type-Options", "nosniff")
+		}
+		if config.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", config.ReferrerPolicy)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,75 @@
+package ginplugin
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/chenparty/gog/resp"
+	"github.com/chenparty/gog/zlog"
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderRequestTimeout 上游剩余的处理时间（毫秒），用于在服务间传递 deadline
+const HeaderRequestTimeout = "Z-Request-Timeout"
+
+// Timeout 为请求上下文设置超时时间，需放在 GinRequestIDForTrace 之后（GinRequestIDForTrace 会替换请求上下文）。
+// routes 按路由配置超时时间，key 可以是 "GET /v1/user" 或 "/v1/user"（路由模板），未配置的路由使用 defaultTimeout，
+// defaultTimeout<=0 时不限制。上游通过 HeaderRequestTimeout 传递的剩余时间更短时以上游为准。
+//
+// 超时后处理函数还未写入响应时返回 504；上游剩余时间已耗尽时直接返回 503。
+// 业务代码应使用 c.Request.Context() 调用各客户端，或开启 gin.Engine.ContextWithFallback 后直接使用 c。
+func Timeout(defaultTimeout time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout, ok := routes[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			timeout, ok = routes[c.FullPath()]
+		}
+		if !ok {
+			timeout = defaultTimeout
+		}
+		if upstream, exist := upstreamTimeout(c); exist {
+			if upstream <= 0 {
+				zlog.Warn().Ctx(c.Request.Context()).Str("path", c.Request.URL.Path).Msg("上游剩余处理时间已耗尽")
+				resp.UnavailableErr.Output().Abort(c)
+				return
+			}
+			if timeout <= 0 || upstream < timeout {
+				timeout = upstream
+			}
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return
+		}
+		if c.Writer.Written() {
+			zlog.Warn().Ctx(ctx).Str("path", c.Request.URL.Path).Dur("timeout", timeout).Msg("请求处理超时，响应已写出")
+			return
+		}
+		zlog.Warn().Ctx(ctx).Str("path", c.Request.URL.Path).Dur("timeout", timeout).Msg("请求处理超时")
+		resp.TimeoutErr.Output().Abort(c)
+	}
+}
+
+// upstreamTimeout 解析上游传递的剩余处理时间
+func upstreamTimeout(c *gin.Context) (timeout time.Duration, exist bool) {
+	val := c.GetHeader(HeaderRequestTimeout)
+	if val == "" {
+		return
+	}
+	ms, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
@@ -34,15 +34,15 @@ func (l *zlogGormLogger) LogMode(_ logger.LogLevel) logger.Interface {
 }
 
 func (l *zlogGormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
-	zlog.Info().Ctx(ctx).Str("source", utils.FileWithLineNum()).Msgf(msg, data)
+	zlog.Info().Ctx(ctx).Str("source", utils.FileWithLineNum()).Msgf(msg, data...)
 }
 
 func (l *zlogGormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
-	zlog.Warn().Ctx(ctx).Str("source", utils.FileWithLineNum()).Msgf(msg, data)
+	zlog.Warn().Ctx(ctx).Str("source", utils.FileWithLineNum()).Msgf(msg, data...)
 }
 
 func (l *zlogGormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
-	zlog.Error().Ctx(ctx).Str("source", utils.FileWithLineNum()).Msgf(msg, data)
+	zlog.Error().Ctx(ctx).Str("source", utils.FileWithLineNum()).Msgf(msg, data...)
 }
 
 func (l *zlogGormLogger) Trace(ctx context.Context, start time.Time, fc func() (string, int64), err error) {
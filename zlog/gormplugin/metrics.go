@@ -0,0 +1,67 @@
+package gormplugin
+
+import (
+	"errors"
+	"time"
+
+	"github.com/chenparty/gog/metrics"
+	"gorm.io/gorm"
+)
+
+const metricsStartKey = "gog:metrics_start"
+
+// NewMetrics 创建记录语句耗时的GORM插件，使用 db.Use(gormplugin.NewMetrics()) 注册
+func NewMetrics() gorm.Plugin {
+	return &metricsPlugin{}
+}
+
+type metricsPlugin struct{}
+
+func (p *metricsPlugin) Name() string {
+	return "gog:metrics"
+}
+
+func (p *metricsPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("gog:metrics_before_create", metricsBefore),
+		cb.Create().After("gorm:create").Register("gog:metrics_after_create", metricsAfter("create")),
+		cb.Query().Before("gorm:query").Register("gog:metrics_before_query", metricsBefore),
+		cb.Query().After("gorm:query").Register("gog:metrics_after_query", metricsAfter("query")),
+		cb.Update().Before("gorm:update").Register("gog:metrics_before_update", metricsBefore),
+		cb.Update().After("gorm:update").Register("gog:metrics_after_update", metricsAfter("update")),
+		cb.Delete().Before("gorm:delete").Register("gog:metrics_before_delete", metricsBefore),
+		cb.Delete().After("gorm:delete").Register("gog:metrics_after_delete", metricsAfter("delete")),
+		cb.Row().Before("gorm:row").Register("gog:metrics_before_row", metricsBefore),
+		cb.Row().After("gorm:row").Register("gog:metrics_after_row", metricsAfter("row")),
+		cb.Raw().Before("gorm:raw").Register("gog:metrics_before_raw", metricsBefore),
+		cb.Raw().After("gorm:raw").Register("gog:metrics_after_raw", metricsAfter("raw")),
+	)
+}
+
+func metricsBefore(db *gorm.DB) {
+	db.InstanceSet(metricsStartKey, time.Now())
+}
+
+func metricsAfter(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		val, ok := db.InstanceGet(metricsStartKey)
+		if !ok {
+			return
+		}
+		start, ok := val.(time.Time)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		err := db.Error
+		// 记录不存在属于正常的查询结果，不计为失败
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		metrics.ObserveGorm(db.Dialector.Name(), operation, table, time.Since(start), err)
+	}
+}
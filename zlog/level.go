@@ -116,7 +116,7 @@ func (l *Level) UnmarshalText(data []byte) error {
 func (l *Level) parse(s string) (err error) {
 	defer func() {
 		if err != nil {
-			err = fmt.Errorf("slog: level string %q: %w", s, err)
+			err = fmt.Errorf("zlog: level string %q: %w", s, err)
 		}
 	}()
 
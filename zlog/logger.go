@@ -1,6 +1,7 @@
 package zlog
 
 import (
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog"
 	"io"
@@ -14,23 +15,67 @@ import (
 var defaultLogger atomic.Pointer[Logger]
 
 func init() {
-	defaultLogger.Store(newLogger(STDOUT, LevelDebug))
+	l, _ := newLogger(STDOUT, LevelDebug)
+	defaultLogger.Store(l)
 }
 func instance() *Logger { return defaultLogger.Load() }
 
+// NewLogLogger 设置全局日志，配置错误时 panic
 func NewLogLogger(mode string, level string, options ...Option) {
+	if err := NewLogLoggerE(mode, level, options...); err != nil {
+		panic(err)
+	}
+}
+
+// NewLogLoggerE 设置全局日志，配置错误时返回所有问题，全局日志保持不变
+func NewLogLoggerE(mode string, level string, options ...Option) error {
 	var m LogMode
 	var le Level
+	var errs []error
 	if err := m.parse(mode); err != nil {
-		panic(fmt.Sprintf("invalid log mode %q: %v", mode, err))
+		errs = append(errs, err)
+	}
+	if err := le.parse(level); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		opts := Options{Mode: m, Level: le}
+		for _, opt := range options {
+			if opt != nil {
+				opt(&opts)
+			}
+		}
+		if err := opts.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
 	}
+	l, err := newLogger(m, le, options...)
+	if err != nil {
+		return err
+	}
+	defaultLogger.Store(l)
+	return nil
+}
+
+// SetLevel 修改全局日志的级别，输出方式保持不变，用于配置中心动态调整日志级别
+func SetLevel(level string) error {
+	var le Level
 	if err := le.parse(level); err != nil {
-		panic(fmt.Sprintf("invalid log level %q: %v", level, err))
+		return err
 	}
-	defaultLogger.Store(newLogger(m, le, options...))
+	zl, err := zerolog.ParseLevel(le.String())
+	if err != nil {
+		return fmt.Errorf("zlog: level %q: %w", level, err)
+	}
+	l := instance().l.Level(zl)
+	defaultLogger.Store(&Logger{l: &l})
+	return nil
 }
 
-func newLogger(mode LogMode, level Level, options ...Option) *Logger {
+func newLogger(mode LogMode, level Level, options ...Option) (*Logger, error) {
 	opts := Options{
 		Mode:  mode,
 		Level: level,
@@ -40,10 +85,14 @@ func newLogger(mode LogMode, level Level, options ...Option) *Logger {
 			opt(&opts)
 		}
 	}
-	l := newZerolog(opts.newWriter(), opts.Level.String())
+	w, err := opts.newWriter()
+	if err != nil {
+		return nil, err
+	}
+	l := newZerolog(w, opts.Level.String())
 	return &Logger{
 		l: &l,
-	}
+	}, nil
 }
 
 type Logger struct {
@@ -1,10 +1,13 @@
 package zlog
 
 import (
+	"errors"
+	"fmt"
 	"github.com/chenparty/gog/zlog/zwriter"
 	"github.com/nats-io/nats.go"
 	"io"
 	"os"
+	"path/filepath"
 )
 
 type Options struct {
@@ -13,19 +16,54 @@ type Options struct {
 
 	FileWriterOption zwriter.FileWriterOption
 	NATSWriterOption zwriter.NATSWriterOption
+
+	Writer io.Writer // 设置后忽略 Mode，直接输出到该 Writer
 }
 
 type Option func(*Options)
 
-func (o Options) newWriter() io.Writer {
+// newWriter 创建日志输出，文件输出时创建日志目录
+func (o Options) newWriter() (io.Writer, error) {
+	if o.Writer != nil {
+		return o.Writer, nil
+	}
 	switch o.Mode {
 	case FILE:
-		return o.FileWriterOption.NewFileWriter()
+		w := o.FileWriterOption.NewFileWriter()
+		if err := os.MkdirAll(filepath.Dir(w.Filename), 0o755); err != nil {
+			return nil, fmt.Errorf("zlog: log file directory: %w", err)
+		}
+		return w, nil
 	case NATS:
-		return o.NATSWriterOption.NewNATSWriter()
+		return o.NATSWriterOption.NewNATSWriter(), nil
 	default:
-		return os.Stdout
+		return os.Stdout, nil
+	}
+}
+
+// validate 检查输出配置，一次返回所有问题；只检查不创建目录，目录在 newWriter 中创建
+func (o Options) validate() error {
+	var errs []error
+	if o.Writer != nil {
+		return nil
+	}
+	switch o.Mode {
+	case FILE:
+		if o.FileWriterOption.MaxSize < 0 {
+			errs = append(errs, fmt.Errorf("zlog: invalid max size %d", o.FileWriterOption.MaxSize))
+		}
+		if o.FileWriterOption.MaxAge < 0 {
+			errs = append(errs, fmt.Errorf("zlog: invalid max age %d", o.FileWriterOption.MaxAge))
+		}
+	case NATS:
+		if o.NATSWriterOption.Connection == nil {
+			errs = append(errs, errors.New("zlog: missing NATS connection"))
+		}
+		if o.NATSWriterOption.Subject == "" {
+			errs = append(errs, errors.New("zlog: missing NATS subject"))
+		}
 	}
+	return errors.Join(errs...)
 }
 
 // FileAttr 使用文件输出日志的配置
@@ -49,3 +87,10 @@ func NATSAttr(conn *nats.Conn, subject string) Option {
 		}
 	}
 }
+
+// WithWriter 输出到自定义的 Writer，如测试中捕获日志
+func WithWriter(w io.Writer) Option {
+	return func(o *Options) {
+		o.Writer = w
+	}
+}